@@ -0,0 +1,78 @@
+package rollrus
+
+import (
+	"context"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+// ContextData is request scoped data found in an entry's context.
+type ContextData struct {
+	// Person identifies the user affected by the report. It is reported as
+	// the item's person if its Id is set.
+	Person *rollbar.Person
+
+	// RequestID is reported as the request_id of the item's request.
+	RequestID string
+
+	// TraceID and SpanID are reported under the trace key of the item's
+	// custom data.
+	TraceID string
+	SpanID  string
+
+	// Custom is merged into the item's custom data as is, so its values
+	// must be JSON serializable. Fields logged with the entry take
+	// precedence over it.
+	Custom map[string]interface{}
+}
+
+// A ContextExtractor returns the data to report from an entry's context. It
+// is only called for entries with a context.
+type ContextExtractor func(ctx context.Context) ContextData
+
+// extractContext adds the data extracted from the entry's context to p.
+func (r *Hook) extractContext(entry *logrus.Entry, p *payload) {
+	if r.contextExtractor == nil || entry.Context == nil {
+		return
+	}
+
+	d := r.contextExtractor(entry.Context)
+	if d.Person != nil && d.Person.Id != "" {
+		p.person = d.Person
+	}
+
+	if d.RequestID != "" {
+		p.request = map[string]interface{}{"request_id": d.RequestID}
+	}
+
+	if d.TraceID != "" || d.SpanID != "" || len(d.Custom) > 0 {
+		p.custom = make(map[string]interface{}, len(d.Custom)+1)
+		for k, v := range d.Custom {
+			p.custom[k] = v
+		}
+	}
+	if d.TraceID != "" || d.SpanID != "" {
+		trace := make(map[string]string)
+		if d.TraceID != "" {
+			trace["trace_id"] = d.TraceID
+		}
+		if d.SpanID != "" {
+			trace["span_id"] = d.SpanID
+		}
+		p.custom["trace"] = trace
+	}
+}
+
+// reportContext returns the context to report the entry with, carrying the
+// person to report, if any.
+func reportContext(entry *logrus.Entry, p *payload) context.Context {
+	ctx := entry.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if p.person != nil {
+		ctx = rollbar.NewPersonContext(ctx, p.person)
+	}
+	return ctx
+}
@@ -0,0 +1,81 @@
+package rollrus
+
+import (
+	"context"
+	"testing"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+type requestIDKey struct{}
+
+func TestWithContextExtractor(t *testing.T) {
+	h, transport := newTestHook(WithContextExtractor(func(ctx context.Context) ContextData {
+		id, _ := ctx.Value(requestIDKey{}).(string)
+		return ContextData{
+			Person:    &rollbar.Person{Id: "42", Username: "gopher", Email: "gopher@example.com"},
+			RequestID: id,
+			TraceID:   "trace",
+			SpanID:    "span",
+			Custom:    map[string]interface{}{"tenant": "acme", "count": 3},
+		}
+	}))
+
+	ctx := context.WithValue(context.Background(), requestIDKey{}, "abc")
+	entry := logrus.NewEntry(nil).WithContext(ctx).WithField("tenant", "field")
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	item := transport.data()[0]
+
+	person := item["person"].(map[string]string)
+	if person["id"] != "42" || person["username"] != "gopher" || person["email"] != "gopher@example.com" {
+		t.Errorf("unexpected person %v", person)
+	}
+
+	if id := item["request"].(map[string]interface{})["request_id"]; id != "abc" {
+		t.Errorf("expected request_id abc, got %v", id)
+	}
+
+	custom := item["custom"].(map[string]interface{})
+	trace := custom["trace"].(map[string]string)
+	if trace["trace_id"] != "trace" || trace["span_id"] != "span" {
+		t.Errorf("unexpected trace %v", trace)
+	}
+	if custom["count"] != 3 {
+		t.Errorf("expected custom data to keep its type, got %#v", custom["count"])
+	}
+	if custom["tenant"] != "field" {
+		t.Errorf("expected fields to take precedence, got %v", custom["tenant"])
+	}
+}
+
+func TestWithContextExtractorWithoutContext(t *testing.T) {
+	called := false
+	h, transport := newTestHook(WithContextExtractor(func(ctx context.Context) ContextData {
+		called = true
+		return ContextData{}
+	}))
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	if called {
+		t.Error("expected extractor not to be called for entries without a context")
+	}
+	item := transport.data()[0]
+	if _, ok := item["person"]; ok {
+		t.Error("expected no person")
+	}
+	if _, ok := item["request"]; ok {
+		t.Error("expected no request")
+	}
+}
@@ -0,0 +1,112 @@
+package rollrus
+
+import (
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// deduper collapses identical reports made within a window into a single
+// report carrying the number of occurrences.
+type deduper struct {
+	window time.Duration
+	report func(*occurrences)
+
+	mu      sync.Mutex
+	pending map[string]*occurrences
+}
+
+// occurrences is a report held back while identical reports are counted.
+type occurrences struct {
+	entry   logrus.Entry
+	err     error
+	fields  map[string]interface{}
+	payload *payload
+
+	count       int
+	first, last time.Time
+	timer       *time.Timer
+}
+
+func newDeduper(window time.Duration, report func(*occurrences)) *deduper {
+	return &deduper{
+		window:  window,
+		report:  report,
+		pending: make(map[string]*occurrences),
+	}
+}
+
+// seen counts an occurrence of the report identified by key at t. It returns
+// false if no identical report is being held back.
+func (d *deduper) seen(key string, t time.Time) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	o, ok := d.pending[key]
+	if !ok {
+		return false
+	}
+
+	o.count++
+	if t.After(o.last) {
+		o.last = t
+	}
+	return true
+}
+
+// hold holds back o, identified by key, until the window has passed.
+func (d *deduper) hold(key string, o *occurrences) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if existing, ok := d.pending[key]; ok {
+		// raced with an identical report.
+		existing.count++
+		return
+	}
+
+	o.count = 1
+	o.first, o.last = o.entry.Time, o.entry.Time
+	o.timer = time.AfterFunc(d.window, func() {
+		d.expire(key, o)
+	})
+	d.pending[key] = o
+}
+
+func (d *deduper) expire(key string, o *occurrences) {
+	d.mu.Lock()
+	if d.pending[key] != o {
+		// already flushed.
+		d.mu.Unlock()
+		return
+	}
+	delete(d.pending, key)
+	d.mu.Unlock()
+
+	d.report(o)
+}
+
+// flush reports everything being held back.
+func (d *deduper) flush() {
+	d.mu.Lock()
+	pending := d.pending
+	d.pending = make(map[string]*occurrences)
+	d.mu.Unlock()
+
+	for _, o := range pending {
+		o.timer.Stop()
+		d.report(o)
+	}
+}
+
+// reportOccurrences reports o with its occurrence count and first and last
+// seen times.
+func (r *Hook) reportOccurrences(o *occurrences) {
+	m := o.fields
+	m["occurrences"] = o.count
+	m["first_seen"] = o.first.Format(time.RFC3339)
+	m["last_seen"] = o.last.Format(time.RFC3339)
+
+	r.report(&o.entry, o.err, m, o.payload)
+}
@@ -0,0 +1,89 @@
+package rollrus
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithDeduplication(t *testing.T) {
+	h, transport := newTestHook(WithDeduplication(time.Hour))
+
+	first := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	fire := func(err error, level logrus.Level, at time.Time) {
+		t.Helper()
+		entry := logrus.NewEntry(nil)
+		entry.Message = "This is a test"
+		entry.Level = level
+		entry.Time = at
+		entry.Data["err"] = err
+		if err := h.Fire(entry); err != nil {
+			t.Fatal("unexpected error ", err)
+		}
+	}
+
+	dup := errors.New("duplicate")
+	for i := 0; i < 3; i++ {
+		fire(dup, logrus.ErrorLevel, first.Add(time.Duration(i)*time.Minute))
+	}
+	fire(errors.New("other"), logrus.ErrorLevel, first)
+
+	if n := len(transport.data()); n != 0 {
+		t.Fatalf("expected reports to be held back, got %d", n)
+	}
+
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	items := transport.data()
+	if len(items) != 2 {
+		t.Fatalf("expected 2 reports once closed, got %d", len(items))
+	}
+
+	for _, item := range items {
+		custom := item["custom"].(map[string]interface{})
+		if item["title"] != "duplicate" {
+			continue
+		}
+		if custom["occurrences"] != 3 {
+			t.Errorf("expected 3 occurrences, got %v", custom["occurrences"])
+		}
+		if custom["first_seen"] != "2020-01-01T00:00:00Z" || custom["last_seen"] != "2020-01-01T00:02:00Z" {
+			t.Errorf("unexpected first/last seen: %v", custom)
+		}
+		if _, ok := custom[payloadKey]; ok {
+			t.Error("expected payload to be removed from the item")
+		}
+
+		// the stack is the one captured when the first was logged.
+		stack := firstTrace(item)["frames"].(rollbar.Stack)
+		if len(stack) == 0 || !strings.HasSuffix(stack[0].Filename, "dedup_test.go") {
+			t.Errorf("expected stack to start in the test, got %v", stack)
+		}
+	}
+}
+
+func TestDeduplicationWindowExpires(t *testing.T) {
+	h, transport := newTestHook(WithDeduplication(time.Millisecond))
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(transport.data()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected report once the window passed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
@@ -13,6 +13,8 @@ package rollrus
 // The levels can be customized with the WithLevels OptionFunc.
 //
 // Specific errors can be ignored with the WithIgnoredErrors OptionFunc. This is
-// useful for ignoring errors such as context.Canceled.
+// useful for ignoring errors such as context.Canceled. Errors of specific types
+// can be ignored with the WithIgnoredErrorTypes OptionFunc. Both match wrapped
+// errors too.
 //
 // See the Examples in the tests for more usage.
@@ -23,3 +23,15 @@ func ExampleNewHook() {
 	// This will be reported to Rollbar
 	log.Panic("Boom.")
 }
+
+func ExampleHook_ReportPanic() {
+	log := logrus.New()
+	hook := NewHook("my-secret-token", "production")
+	log.Hooks.Add(hook)
+
+	// Panics are reported with the panicking goroutine's stack trace, using
+	// the hook's options, before re-panicking.
+	defer hook.ReportPanic()
+
+	panic("Boom.")
+}
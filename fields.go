@@ -0,0 +1,172 @@
+package rollrus
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// defaultMaxFieldDepth and defaultMaxFieldSize are the limits used by
+	// WithStructuredFields when none are given.
+	defaultMaxFieldDepth = 5
+	defaultMaxFieldSize  = 100
+)
+
+// fieldConverter converts field values to types that can be serialized as
+// JSON, keeping their structure within its limits.
+type fieldConverter struct {
+	maxDepth int
+	maxSize  int
+}
+
+// convertFields converts fields to custom data.
+func (c *fieldConverter) convertFields(fields logrus.Fields) map[string]interface{} {
+	m := make(map[string]interface{}, len(fields))
+	for k, v := range fields {
+		m[k] = c.convert(v, 1)
+	}
+	return m
+}
+
+// convert converts v, nested depth levels deep. Values that can't be
+// serialized, or that are nested too deep, are converted to strings.
+func (c *fieldConverter) convert(v interface{}, depth int) interface{} {
+	switch t := v.(type) {
+	case nil:
+		return nil
+	case time.Time:
+		return t.Format(time.RFC3339)
+	case error:
+		return t.Error()
+	case json.Marshaler:
+		return c.convertJSON(v, depth)
+	case encoding.TextMarshaler:
+		if b, err := t.MarshalText(); err == nil {
+			return string(b)
+		}
+		return stringify(v)
+	case fmt.Stringer:
+		return t.String()
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Bool:
+		return rv.Bool()
+	case reflect.String:
+		return rv.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return rv.Uint()
+	case reflect.Float32, reflect.Float64:
+		if f := rv.Float(); !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f
+		}
+	case reflect.Ptr, reflect.Interface:
+		if rv.IsNil() {
+			return nil
+		}
+		return c.convert(rv.Elem().Interface(), depth)
+	}
+
+	if depth > c.maxDepth {
+		return stringify(v)
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		return c.convertMap(rv, depth)
+	case reflect.Slice:
+		if rv.IsNil() {
+			return nil
+		}
+		if b, ok := v.([]byte); ok && utf8.Valid(b) {
+			return string(b)
+		}
+		return c.convertSlice(rv, depth)
+	case reflect.Array:
+		return c.convertSlice(rv, depth)
+	case reflect.Struct:
+		return c.convertJSON(v, depth)
+	}
+
+	return stringify(v)
+}
+
+// convertMap converts a map to a map of strings, keeping the first maxSize
+// keys in sorted order.
+func (c *fieldConverter) convertMap(rv reflect.Value, depth int) interface{} {
+	if rv.IsNil() {
+		return nil
+	}
+
+	keys := make([]string, 0, rv.Len())
+	values := make(map[string]reflect.Value, rv.Len())
+	for _, k := range rv.MapKeys() {
+		key := fmt.Sprint(k.Interface())
+		keys = append(keys, key)
+		values[key] = rv.MapIndex(k)
+	}
+	sort.Strings(keys)
+
+	m := make(map[string]interface{}, len(keys))
+	for i, k := range keys {
+		if i == c.maxSize {
+			m["..."] = fmt.Sprintf("%d more", len(keys)-i)
+			break
+		}
+		m[k] = c.convert(values[k].Interface(), depth+1)
+	}
+	return m
+}
+
+// convertSlice converts a slice or array, keeping its first maxSize
+// elements.
+func (c *fieldConverter) convertSlice(rv reflect.Value, depth int) interface{} {
+	n := rv.Len()
+	s := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		if i == c.maxSize {
+			s = append(s, fmt.Sprintf("... %d more", n-i))
+			break
+		}
+		s = append(s, c.convert(rv.Index(i).Interface(), depth+1))
+	}
+	return s
+}
+
+// convertJSON converts v through its JSON encoding, so that struct tags and
+// custom marshalers are respected.
+func (c *fieldConverter) convertJSON(v interface{}, depth int) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return stringify(v)
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(b, &decoded); err != nil {
+		return stringify(v)
+	}
+
+	switch decoded.(type) {
+	case map[string]interface{}, []interface{}:
+		if depth > c.maxDepth {
+			return string(b)
+		}
+	}
+	return c.convert(decoded, depth)
+}
+
+// stringify formats v the way convertFields does.
+func stringify(v interface{}) string {
+	return fmt.Sprintf("%+v", v)
+}
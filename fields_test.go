@@ -0,0 +1,94 @@
+package rollrus
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+type account struct {
+	ID     int    `json:"id"`
+	Name   string `json:"name"`
+	secret string
+}
+
+type node struct {
+	Next *node
+}
+
+func TestFieldConverter(t *testing.T) {
+	c := &fieldConverter{maxDepth: 2, maxSize: 2}
+	when := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	cases := []struct {
+		name     string
+		value    interface{}
+		expected interface{}
+	}{
+		{"nil", nil, nil},
+		{"bool", true, true},
+		{"int", 42, int64(42)},
+		{"uint", uint8(7), uint64(7)},
+		{"float", 1.5, 1.5},
+		{"nan", math.NaN(), "NaN"},
+		{"string", "foo", "foo"},
+		{"bytes", []byte("foo"), "foo"},
+		{"time", when, "2020-01-02T03:04:05Z"},
+		{"duration", time.Second, "1s"},
+		{"error", errors.New("boom"), "boom"},
+		{"pointer", &account{ID: 1}, map[string]interface{}{"id": float64(1), "name": ""}},
+		{"nil pointer", (*account)(nil), nil},
+		{"struct", account{ID: 1, Name: "acme", secret: "x"}, map[string]interface{}{"id": float64(1), "name": "acme"}},
+		{"slice", []int{1, 2}, []interface{}{int64(1), int64(2)}},
+		{"long slice", []int{1, 2, 3}, []interface{}{int64(1), int64(2), "... 1 more"}},
+		{"map", map[int]bool{1: true}, map[string]interface{}{"1": true}},
+		{"large map", map[string]int{"a": 1, "b": 2, "c": 3}, map[string]interface{}{"a": int64(1), "b": int64(2), "...": "1 more"}},
+		{"nested", map[string]interface{}{"a": []interface{}{[]int{1}}}, map[string]interface{}{"a": []interface{}{"[1]"}}},
+		{"nested struct", &node{Next: &node{Next: &node{}}}, map[string]interface{}{"Next": map[string]interface{}{"Next": "map[Next:<nil>]"}}},
+		{"func", func() {}, nil},
+		{"chan", make(chan int), nil},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := c.convert(tc.value, 1)
+			switch tc.name {
+			case "func", "chan":
+				if _, ok := got.(string); !ok {
+					t.Errorf("expected a string, got %#v", got)
+				}
+				return
+			}
+			if !reflect.DeepEqual(got, tc.expected) {
+				t.Errorf("expected %#v, got %#v", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestWithStructuredFields(t *testing.T) {
+	h, transport := newTestHook(WithStructuredFields(0, 0))
+
+	entry := logrus.NewEntry(nil).WithFields(logrus.Fields{
+		"count":   3,
+		"ok":      false,
+		"account": account{ID: 1, Name: "acme"},
+	})
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	custom := transport.data()[0]["custom"].(map[string]interface{})
+	if custom["count"] != int64(3) || custom["ok"] != false {
+		t.Errorf("expected native types, got %#v and %#v", custom["count"], custom["ok"])
+	}
+	if name := custom["account"].(map[string]interface{})["name"]; name != "acme" {
+		t.Errorf("expected structs to be converted to maps, got %#v", custom["account"])
+	}
+}
@@ -0,0 +1,46 @@
+package rollrus
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/sirupsen/logrus"
+)
+
+var (
+	uuidPattern   = regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`)
+	hexPattern    = regexp.MustCompile(`(?i)\b(?:0x[0-9a-f]+|[0-9a-f]*[0-9][0-9a-f]*[a-f][0-9a-f]*|[0-9a-f]*[a-f][0-9a-f]*[0-9][0-9a-f]*)\b`)
+	numberPattern = regexp.MustCompile(`[0-9]+(?:\.[0-9]+)?`)
+)
+
+// NormalizeMessage replaces the UUIDs, hexadecimal strings and numbers in
+// msg with placeholders, so that messages embedding IDs can be grouped.
+func NormalizeMessage(msg string) string {
+	msg = uuidPattern.ReplaceAllLiteralString(msg, "<uuid>")
+	msg = hexPattern.ReplaceAllLiteralString(msg, "<hex>")
+	return numberPattern.ReplaceAllLiteralString(msg, "<n>")
+}
+
+// NormalizedFingerprint can be passed to WithFingerprint to group reports by
+// the type of their error and its message, normalized by NormalizeMessage.
+// Entries without an error are grouped by their normalized message.
+func NormalizedFingerprint(entry *logrus.Entry, err error, fields map[string]interface{}) string {
+	if err == nil {
+		return NormalizeMessage(entry.Message)
+	}
+	return fmt.Sprintf("%T: %s", errorCause(err), NormalizeMessage(err.Error()))
+}
+
+// FireWithFingerprint works like Fire, but groups the report into the Rollbar
+// item identified by fingerprint, overriding WithFingerprint.
+func (r *Hook) FireWithFingerprint(entry *logrus.Entry, fingerprint string) error {
+	return r.fire(entry, &payload{fingerprint: fingerprint})
+}
+
+// extractFingerprint sets the report's fingerprint from the hook's
+// fingerprint func, unless it was already set.
+func (r *Hook) extractFingerprint(entry *logrus.Entry, err error, m map[string]interface{}, p *payload) {
+	if p.fingerprint == "" && r.fingerprintFunc != nil {
+		p.fingerprint = r.fingerprintFunc(entry, err, m)
+	}
+}
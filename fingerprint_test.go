@@ -0,0 +1,79 @@
+package rollrus
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestNormalizeMessage(t *testing.T) {
+	cases := []struct {
+		msg, expected string
+	}{
+		{"user 1234 not found", "user <n> not found"},
+		{"took 1.5s", "took <n>s"},
+		{"app 3f2504e0-4f89-11d3-9a0c-0305e82c3301 crashed", "app <uuid> crashed"},
+		{"commit deadbeef42 at 0x1F failed", "commit <hex> at <hex> failed"},
+		{"cafe failed", "cafe failed"},
+	}
+
+	for _, c := range cases {
+		if got := NormalizeMessage(c.msg); got != c.expected {
+			t.Errorf("%q: expected %q, got %q", c.msg, c.expected, got)
+		}
+	}
+}
+
+func TestWithFingerprint(t *testing.T) {
+	h, transport := newTestHook(WithFingerprint(NormalizedFingerprint))
+
+	for _, id := range []string{"1234", "5678"} {
+		entry := logrus.NewEntry(nil).WithField("err", errors.New("user "+id+" not found"))
+		entry.Message = "This is a test"
+		entry.Level = logrus.ErrorLevel
+		if err := h.Fire(entry); err != nil {
+			t.Fatal("unexpected error ", err)
+		}
+	}
+
+	items := transport.data()
+	expected := "*errors.errorString: user <n> not found"
+	for _, item := range items {
+		if item["fingerprint"] != expected {
+			t.Errorf("expected fingerprint %q, got %v", expected, item["fingerprint"])
+		}
+	}
+}
+
+func TestWithFingerprintEmpty(t *testing.T) {
+	h, transport := newTestHook(WithFingerprint(func(*logrus.Entry, error, map[string]interface{}) string {
+		return ""
+	}))
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	if _, ok := transport.data()[0]["fingerprint"]; ok {
+		t.Error("expected no fingerprint")
+	}
+}
+
+func TestFireWithFingerprint(t *testing.T) {
+	h, transport := newTestHook(WithFingerprint(NormalizedFingerprint))
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.FireWithFingerprint(entry, "custom"); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	if fingerprint := transport.data()[0]["fingerprint"]; fingerprint != "custom" {
+		t.Errorf("expected fingerprint custom, got %v", fingerprint)
+	}
+}
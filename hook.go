@@ -1,9 +1,16 @@
 package rollrus
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"os"
+	"reflect"
 	"runtime"
 	"strings"
+	"sync/atomic"
+	"text/template"
 	"time"
 
 	"github.com/rollbar/rollbar-go"
@@ -15,28 +22,180 @@ import (
 var _ logrus.Hook = &Hook{} //assert that *Hook is a logrus.Hook
 
 // Hook is a wrapper for the Rollbar Client and is usable as a logrus.Hook.
+// The Hook relies on the Client's transform, so transforms must be set with
+// Hook.SetTransform rather than directly on the Client.
 type Hook struct {
 	*rollbar.Client
 	triggers        []logrus.Level
 	ignoredErrors   []error
+	ignoredTypes    []reflect.Type
 	ignoreErrorFunc func(error) bool
 	ignoreFunc      func(error, map[string]interface{}) bool
+	fingerprintFunc func(*logrus.Entry, error, map[string]interface{}) string
+	titleTemplate   *template.Template
+	levelMapper     func(*logrus.Entry) string
 
-	// only used for tests to verify whether or not a report happened.
-	reported bool
+	recoverPanics bool
+	panicFunc     func(context.Context, interface{})
+
+	spoolDir     string
+	spoolMaxSize int64
+	spoolMaxAge  time.Duration
+
+	transportOpts []transport.Option
+	flushTimeout  time.Duration
+	limiter       *rateLimiter
+	deduper       *deduper
+	sampling      *Sampling
+
+	contextExtractor ContextExtractor
+	personFields     *personFields
+	requestFields    []string
+	fields           *fieldConverter
+
+	customScrubbers   scrubbers
+	noDefaultScrubber bool
+	maxPayloadSize    int
+
+	// only used for tests to verify whether or not a report happened. It is
+	// set atomically as reports are made from other goroutines.
+	reported int32
+}
+
+// SetTransform sets a function that modifies items before they are sent to
+// Rollbar. It runs after the Hook's own transform, which applies scrubbing
+// and truncation among others, so what it adds is sent as is.
+func (r *Hook) SetTransform(t func(map[string]interface{})) {
+	if t == nil {
+		r.Client.SetTransform(transform)
+		return
+	}
+	r.Client.SetTransform(func(data map[string]interface{}) {
+		transform(data)
+		t(data)
+	})
 }
 
 // NewHookForLevels provided by the caller. Otherwise works like NewHook.
 func NewHookForLevels(token string, env string, levels []logrus.Level) *Hook {
-	client := rollbar.NewSync(token, env, "", "", "")
-	client.Transport = transport.NewBuffered(client.Transport, rollbar.DefaultBuffer)
+	return newHook(token, env, levels)
+}
 
-	return &Hook{
-		Client:          client,
+func newHook(token string, env string, levels []logrus.Level, opts ...OptionFunc) *Hook {
+	h := &Hook{
+		Client:          rollbar.NewSync(token, env, "", "", ""),
 		triggers:        levels,
 		ignoredErrors:   make([]error, 0),
 		ignoreErrorFunc: func(error) bool { return false },
 		ignoreFunc:      func(error, map[string]interface{}) bool { return false },
+		flushTimeout:    defaultFlushTimeout,
+		maxPayloadSize:  defaultMaxPayloadSize,
+	}
+	h.Client.SetTransform(transform)
+	h.Client.SetScrubHeaders(defaultScrubHeaders)
+
+	for _, o := range opts {
+		o(h)
+	}
+
+	// the transport is built last since options may configure it.
+	h.Client.Transport = h.newTransport(h.Client.Transport)
+
+	return h
+}
+
+// newTransport wraps the inner transport for async delivery, spooling to disk
+// when configured to do so.
+func (r *Hook) newTransport(inner rollbar.Transport) rollbar.Transport {
+	if r.spoolDir != "" {
+		t, err := transport.NewSpooled(inner, rollbar.DefaultBuffer, r.spoolDir, r.spoolMaxSize, r.spoolMaxAge, r.transportOpts...)
+		if err == nil {
+			return t
+		}
+		fmt.Fprintf(os.Stderr, "rollrus: unable to spool to %s, falling back to memory: %v\n", r.spoolDir, err)
+	}
+
+	return transport.NewBuffered(inner, rollbar.DefaultBuffer, r.transportOpts...)
+}
+
+// Stats returns a snapshot of the hook's report delivery counters. It
+// returns zero Stats if the Client's Transport has been replaced.
+func (r *Hook) Stats() Stats {
+	if t, ok := r.Client.Transport.(*transport.Buffered); ok {
+		return t.Stats()
+	}
+	return Stats{}
+}
+
+// contextTransport is implemented by transports that can give up on delivery.
+type contextTransport interface {
+	WaitContext(context.Context) error
+	CloseContext(context.Context) error
+}
+
+// Flush blocks until all reports made before calling Flush are delivered. If
+// ctx is done first, Flush returns an *AbandonedError with the number of
+// reports not yet delivered; they remain queued.
+func (r *Hook) Flush(ctx context.Context) error {
+	if t, ok := r.Client.Transport.(contextTransport); ok {
+		return t.WaitContext(ctx)
+	}
+	return waitContext(ctx, r.Client.Wait)
+}
+
+// Close delivers all pending reports and shuts down the hook. Reports made
+// after calling Close are dropped. If ctx is done first, delivery stops and
+// Close returns an *AbandonedError with the number of reports abandoned.
+func (r *Hook) Close(ctx context.Context) error {
+	r.flushPending()
+
+	if t, ok := r.Client.Transport.(contextTransport); ok {
+		return t.CloseContext(ctx)
+	}
+	return waitContext(ctx, func() { _ = r.Client.Close() })
+}
+
+// waitContext runs fn, returning early with ctx's error if ctx is done first.
+func waitContext(ctx context.Context, fn func()) error {
+	done := make(chan struct{})
+	go func() {
+		fn()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// flushPending reports everything held back by deduplication and rate
+// limiting.
+func (r *Hook) flushPending() {
+	if r.deduper != nil {
+		r.deduper.flush()
+	}
+	if r.limiter != nil {
+		r.limiter.flush()
+	}
+}
+
+// flush reports anything held back and waits for pending reports to be
+// delivered, for up to flushTimeout.
+func (r *Hook) flush() {
+	r.flushPending()
+
+	ctx := context.Background()
+	if r.flushTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.flushTimeout)
+		defer cancel()
+	}
+
+	if err := r.Flush(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "rollrus: %v\n", err)
 	}
 }
 
@@ -51,19 +210,23 @@ func (r *Hook) Levels() []logrus.Level {
 // Fire the hook. This is called by Logrus for entries that match the levels
 // returned by Levels().
 func (r *Hook) Fire(entry *logrus.Entry) error {
+	return r.fire(entry, &payload{})
+}
+
+// fire reports the entry, applying p to the report.
+func (r *Hook) fire(entry *logrus.Entry, p *payload) error {
 	err := extractError(entry)
 	cause := errorCause(err)
-	for _, ie := range r.ignoredErrors {
-		if ie == cause {
-			return nil
-		}
-	}
-
-	if r.ignoreErrorFunc(cause) {
+	if r.ignored(err) || r.ignoreErrorFunc(cause) {
 		return nil
 	}
 
-	m := convertFields(entry.Data)
+	var m map[string]interface{}
+	if r.fields != nil {
+		m = r.fields.convertFields(entry.Data)
+	} else {
+		m = convertFields(entry.Data)
+	}
 	if _, exists := m["time"]; !exists {
 		m["time"] = entry.Time.Format(time.RFC3339)
 	}
@@ -76,34 +239,142 @@ func (r *Hook) Fire(entry *logrus.Entry) error {
 		return nil
 	}
 
-	r.report(entry, err, m)
+	if r.sampling != nil {
+		rate, ok := r.sampling.sample(entry)
+		if !ok {
+			return nil
+		}
+		if rate < 1 {
+			m[sampleRateField] = rate
+		}
+	}
+
+	// Fatal and Panic entries are always reported right away, unless the
+	// panic was recovered and the program carries on.
+	var key string
+	if (entry.Level > logrus.FatalLevel || p.recovered) && (r.deduper != nil || r.limiter != nil) {
+		key = errorFingerprint(cause)
+	}
+
+	if r.deduper != nil && key != "" && r.deduper.seen(key, entryTime(entry)) {
+		return nil
+	}
+
+	if r.limiter != nil && key != "" && !r.limiter.allow(key) {
+		return nil
+	}
+
+	r.extractContext(entry, p)
+	r.extractPerson(entry, m, p)
+	r.extractRequest(entry, m, p)
+	r.extractFingerprint(entry, err, m, p)
+	if r.titleTemplate != nil {
+		p.title = r.renderTitle(entry, err)
+	}
+	p.level = r.rollbarLevel(entry)
+
+	if r.deduper != nil && key != "" {
+		e := *entry
+		e.Data = nil
+		e.Time = entryTime(entry)
+		if p.stack == nil {
+			p.stack = callerStack()
+		}
+		r.deduper.hold(key, &occurrences{entry: e, err: err, fields: m, payload: p})
+	} else {
+		r.report(entry, err, m, p)
+	}
 
 	return nil
 }
 
-func (r *Hook) report(entry *logrus.Entry, cause error, m map[string]interface{}) {
+// report sends the entry to Rollbar. p, if not nil, is applied to the item
+// by transform.
+func (r *Hook) report(entry *logrus.Entry, cause error, m map[string]interface{}, p *payload) {
 	level := entry.Level
+	if p == nil {
+		p = &payload{level: r.rollbarLevel(entry)}
+	}
+	p.chain = errorChain(cause)
+	p.scrubbers = r.scrubbers()
+	p.maxSize = r.maxPayloadSize
+	m[payloadKey] = p
+	ctx := reportContext(entry, p)
+
+	atomic.StoreInt32(&r.reported, 1)
+
+	// skip report, fire and Fire. framesToSkip accounts for the client
+	// calling its AndContext variant, which is called directly here.
+	skip := framesToSkip(3) - 1
+
+	switch level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		r.reportError(ctx, p.level, p.req, cause, skip, m)
+		if !p.recovered {
+			r.flush()
+		}
+	case logrus.ErrorLevel, logrus.WarnLevel:
+		r.reportError(ctx, p.level, p.req, cause, skip, m)
+	default:
+		r.reportMessage(ctx, p.level, p.req, entry.Message, m)
+	}
+}
+
+// rollbarLevel returns the Rollbar level to report the entry at.
+func (r *Hook) rollbarLevel(entry *logrus.Entry) string {
+	if r.levelMapper != nil {
+		if l := r.levelMapper(entry); l != "" {
+			return l
+		}
+	}
+	return DefaultLevel(entry)
+}
+
+// DefaultLevel returns the Rollbar level entries are reported at by default:
+// critical for Panic and Fatal, error, warning and info for Error, Warn and
+// Info, and debug for Debug and Trace.
+func DefaultLevel(entry *logrus.Entry) string {
+	switch entry.Level {
+	case logrus.PanicLevel, logrus.FatalLevel:
+		return rollbar.CRIT
+	case logrus.ErrorLevel:
+		return rollbar.ERR
+	case logrus.WarnLevel:
+		return rollbar.WARN
+	case logrus.InfoLevel:
+		return rollbar.INFO
+	default:
+		return rollbar.DEBUG
+	}
+}
 
-	r.reported = true
+// reportError reports err, along with req if it isn't nil.
+func (r *Hook) reportError(ctx context.Context, level string, req *http.Request, err error, skip int, m map[string]interface{}) {
+	// skip this function's frame too.
+	skip++
 
-	switch {
-	case level == logrus.FatalLevel || level == logrus.PanicLevel:
-		skip := framesToSkip(2)
-		r.Client.ErrorWithStackSkipWithExtras(rollbar.CRIT, cause, skip, m)
-		r.Client.Wait()
-	case level == logrus.ErrorLevel:
-		skip := framesToSkip(2)
-		r.Client.ErrorWithStackSkipWithExtras(rollbar.ERR, cause, skip, m)
-	case level == logrus.WarnLevel:
-		skip := framesToSkip(2)
-		r.Client.ErrorWithStackSkipWithExtras(rollbar.WARN, cause, skip, m)
-	case level == logrus.InfoLevel:
-		r.Client.MessageWithExtras(rollbar.INFO, entry.Message, m)
-	case level == logrus.DebugLevel:
-		r.Client.MessageWithExtras(rollbar.DEBUG, entry.Message, m)
-	case level == logrus.TraceLevel:
-		r.Client.MessageWithExtras(rollbar.DEBUG, entry.Message, m)
+	if req != nil {
+		r.Client.RequestErrorWithStackSkipWithExtrasAndContext(ctx, level, req, err, skip, m)
+		return
 	}
+	r.Client.ErrorWithStackSkipWithExtrasAndContext(ctx, level, err, skip, m)
+}
+
+// reportMessage reports msg, along with req if it isn't nil.
+func (r *Hook) reportMessage(ctx context.Context, level string, req *http.Request, msg string, m map[string]interface{}) {
+	if req != nil {
+		r.Client.RequestMessageWithExtrasAndContext(ctx, level, req, msg, m)
+		return
+	}
+	r.Client.MessageWithExtrasAndContext(ctx, level, msg, m)
+}
+
+// entryTime returns when the entry was logged, or now if that's unknown.
+func entryTime(entry *logrus.Entry) time.Time {
+	if entry.Time.IsZero() {
+		return time.Now()
+	}
+	return entry.Time
 }
 
 // convertFields converts from log.Fields to map[string]interface{} so that we can
@@ -170,17 +441,61 @@ func framesToSkip(rollrusSkip int) int {
 	return skip + 2 - 1
 }
 
-func errorCause(err error) error {
+// maxErrorChain bounds the number of errors followed in an error chain, in
+// case of cycles.
+const maxErrorChain = 100
+
+// errorChain returns err followed by the errors it wraps, as returned by
+// their Unwrap or pkg/errors style Cause methods.
+func errorChain(err error) []error {
 	type causer interface {
 		Cause() error
 	}
 
-	for err != nil {
-		cause, ok := err.(causer)
-		if !ok {
-			break
+	var chain []error
+	for err != nil && len(chain) < maxErrorChain {
+		chain = append(chain, err)
+
+		switch e := err.(type) {
+		case interface{ Unwrap() error }:
+			err = e.Unwrap()
+		case causer:
+			err = e.Cause()
+		default:
+			err = nil
+		}
+	}
+	return chain
+}
+
+// errorCause returns the innermost error in err's chain.
+func errorCause(err error) error {
+	chain := errorChain(err)
+	if len(chain) == 0 {
+		return nil
+	}
+	return chain[len(chain)-1]
+}
+
+// ignored reports whether any error in err's chain matches the ignored
+// errors, per errors.Is, or the ignored error types, per errors.As.
+func (r *Hook) ignored(err error) bool {
+	if len(r.ignoredErrors) == 0 && len(r.ignoredTypes) == 0 {
+		return false
+	}
+
+	for _, e := range errorChain(err) {
+		for _, ie := range r.ignoredErrors {
+			if errors.Is(e, ie) {
+				return true
+			}
+		}
+
+		for _, typ := range r.ignoredTypes {
+			if errors.As(e, reflect.New(typ).Interface()) {
+				return true
+			}
 		}
-		err = cause.Cause()
 	}
-	return err
+	return false
 }
@@ -1,11 +1,17 @@
 package rollrus
 
 import (
+	"context"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -187,7 +193,7 @@ func TestLoggingBelowTheMinimumLevelDoesNotFire(t *testing.T) {
 
 	l.Error("This is a test")
 
-	if h.reported {
+	if reported(h) {
 		t.Fatal("expected no report to have happened")
 	}
 }
@@ -198,7 +204,7 @@ func TestLoggingAboveTheMinimumLevelDoesFire(t *testing.T) {
 
 	l.Warn("This is a test")
 
-	if !h.reported {
+	if !reported(h) {
 		t.Fatal("expected report to have happened")
 	}
 }
@@ -213,7 +219,7 @@ func TestWithIgnoredErrors(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if h.reported {
+	if reported(h) {
 		t.Fatal("expected no report to have happened")
 	}
 
@@ -222,7 +228,7 @@ func TestWithIgnoredErrors(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if h.reported {
+	if reported(h) {
 		t.Fatal("expected no report to have happened")
 	}
 
@@ -231,7 +237,7 @@ func TestWithIgnoredErrors(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if !h.reported {
+	if !reported(h) {
 		t.Fatal("expected a report to have happened")
 	}
 
@@ -240,7 +246,7 @@ func TestWithIgnoredErrors(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if !h.reported {
+	if !reported(h) {
 		t.Fatal("expected a report to have happened")
 	}
 }
@@ -272,6 +278,92 @@ func TestWithErrorHandlesUnhashableErrors(t *testing.T) {
 	}
 }
 
+func TestWithIgnoredErrorsHandlesUnhashableErrors(t *testing.T) {
+	h := NewHook("", "testing", WithIgnoredErrors(PGError{m: make(map[byte]string)}))
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Data["err"] = fmt.Errorf("wrapped: %w", PGError{m: make(map[byte]string)})
+
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if !reported(h) {
+		t.Fatal("expected a report to have happened")
+	}
+}
+
+func TestWithIgnoredErrorsUnwrap(t *testing.T) {
+	h := NewHook("", "testing", WithIgnoredErrors(io.EOF))
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+
+	// Unwrap and Cause chains can be mixed.
+	entry.Data["err"] = errors.Wrap(fmt.Errorf("reading: %w", io.EOF), "hello")
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if reported(h) {
+		t.Fatal("expected no report to have happened")
+	}
+}
+
+func TestWithIgnoredErrorTypes(t *testing.T) {
+	h := NewHook("", "testing", WithIgnoredErrorTypes(new(*os.PathError), new(isTemporary)))
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+
+	_, err := os.Open("/does/not/exist")
+	entry.Data["err"] = fmt.Errorf("opening: %w", err)
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if reported(h) {
+		t.Fatal("expected no report to have happened")
+	}
+
+	entry.Data["err"] = errors.Wrap(temporaryError{}, "hello")
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if reported(h) {
+		t.Fatal("expected no report to have happened")
+	}
+
+	entry.Data["err"] = io.EOF
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if !reported(h) {
+		t.Fatal("expected a report to have happened")
+	}
+}
+
+func TestWithIgnoredErrorTypesPanicsOnInvalidTarget(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected a panic")
+		}
+	}()
+	WithIgnoredErrorTypes(os.PathError{})
+}
+
+type temporaryError struct{}
+
+func (temporaryError) Error() string   { return "temporary" }
+func (temporaryError) Temporary() bool { return true }
+
+func TestErrorCause(t *testing.T) {
+	cause := errorCause(fmt.Errorf("outer: %w", errors.Wrap(io.EOF, "inner")))
+	if cause != io.EOF {
+		t.Fatalf("expected io.EOF, got %v", cause)
+	}
+
+	nilCauser := NilCauserError{error: io.EOF}
+	if cause := errorCause(nilCauser); cause != nilCauser {
+		t.Fatalf("expected the last non-nil error, got %v", cause)
+	}
+}
+
 func TestWithIgnoreErrorFunc(t *testing.T) {
 	h := NewHook("", "testing", WithIgnoreErrorFunc(func(err error) bool {
 		if err == io.EOF {
@@ -293,7 +385,7 @@ func TestWithIgnoreErrorFunc(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if h.reported {
+	if reported(h) {
 		t.Fatal("expected no report to have happened")
 	}
 
@@ -302,7 +394,7 @@ func TestWithIgnoreErrorFunc(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if h.reported {
+	if reported(h) {
 		t.Fatal("expected no report to have happened")
 	}
 
@@ -322,7 +414,7 @@ func TestWithIgnoreErrorFunc(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if !h.reported {
+	if !reported(h) {
 		t.Fatal("expected a report to have happened")
 	}
 
@@ -331,7 +423,7 @@ func TestWithIgnoreErrorFunc(t *testing.T) {
 	if err := h.Fire(entry); err != nil {
 		t.Fatal("unexpected error ", err)
 	}
-	if !h.reported {
+	if !reported(h) {
 		t.Fatal("expected a report to have happened")
 	}
 }
@@ -390,11 +482,11 @@ func TestWithIgnoreFunc(t *testing.T) {
 				t.Errorf("unexpected error %s", err)
 			}
 
-			if c.skipReport && h.reported {
+			if c.skipReport && reported(h) {
 				t.Errorf("expected report to be skipped")
 			}
 
-			if !c.skipReport && !h.reported {
+			if !c.skipReport && !reported(h) {
 				t.Errorf("expected report to be fired")
 			}
 		})
@@ -408,3 +500,207 @@ func TestDynamicFrameSkipping(t *testing.T) {
 		t.Fatalf("expected frames to skip to be 2, got %d", skip)
 	}
 }
+
+func TestWithSpool(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rollrus-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	h := NewHook("", "testing", WithSpool(filepath.Join(dir, "spool"), 0, 0))
+	defer h.Close(context.Background())
+
+	if _, err := os.Stat(filepath.Join(dir, "spool")); err != nil {
+		t.Fatal("expected spool dir to be created: ", err)
+	}
+}
+
+func TestStats(t *testing.T) {
+	h := NewHook("", "testing")
+	h.SetLogger(&rollbar.SilentClientLogger{})
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	h.Wait()
+
+	if s := h.Stats(); s.Enqueued != 1 || s.Sent != 1 {
+		t.Fatalf("expected 1 report to be enqueued and sent, got %+v", s)
+	}
+}
+
+func TestFlushAndClose(t *testing.T) {
+	h := NewHook("", "testing")
+	h.SetLogger(&rollbar.SilentClientLogger{})
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	if err := h.Flush(context.Background()); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if err := h.Close(context.Background()); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	// a canceled context doesn't matter once everything has been delivered.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := h.Close(ctx); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	if s := h.Stats(); s.Sent != 1 {
+		t.Fatalf("expected 1 report to be sent, got %+v", s)
+	}
+}
+
+// reported reports whether h has made a report.
+func reported(h *Hook) bool {
+	return atomic.LoadInt32(&h.reported) == 1
+}
+
+// testTransport records the items sent through it.
+type testTransport struct {
+	rollbar.Transport
+
+	mu    sync.Mutex
+	items []map[string]interface{}
+}
+
+// newTestHook returns a hook that records items instead of sending them.
+func newTestHook(opts ...OptionFunc) (*Hook, *testTransport) {
+	h := NewHook("", "testing", opts...)
+	t := &testTransport{Transport: h.Client.Transport}
+	h.Client.Transport = t
+	return h, t
+}
+
+func (t *testTransport) Send(body map[string]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = append(t.items, body["data"].(map[string]interface{}))
+	return nil
+}
+
+// data returns the data of the items sent so far.
+func (t *testTransport) data() []map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]map[string]interface{}(nil), t.items...)
+}
+
+func TestWithLevelMapper(t *testing.T) {
+	h, transport := newTestHook(WithLevelMapper(func(entry *logrus.Entry) string {
+		if entry.Data["severity"] == "high" {
+			return rollbar.CRIT
+		}
+		return ""
+	}))
+
+	for _, c := range []struct {
+		level    logrus.Level
+		severity string
+		expected string
+	}{
+		{logrus.ErrorLevel, "high", rollbar.CRIT},
+		{logrus.ErrorLevel, "", rollbar.ERR},
+		{logrus.WarnLevel, "", rollbar.WARN},
+		{logrus.TraceLevel, "", rollbar.DEBUG},
+	} {
+		entry := logrus.NewEntry(nil).WithField("severity", c.severity)
+		entry.Message = "This is a test"
+		entry.Level = c.level
+		if err := h.Fire(entry); err != nil {
+			t.Fatal("unexpected error ", err)
+		}
+
+		items := transport.data()
+		if level := items[len(items)-1]["level"]; level != c.expected {
+			t.Errorf("%s with severity %q: expected %s, got %v", c.level, c.severity, c.expected, level)
+		}
+	}
+}
+
+func TestWithLevelMapperDeduplicated(t *testing.T) {
+	h, transport := newTestHook(
+		WithDeduplication(time.Hour),
+		WithLevelMapper(func(entry *logrus.Entry) string {
+			if entry.Data["severity"] == "high" {
+				return rollbar.CRIT
+			}
+			return ""
+		}),
+	)
+
+	entry := logrus.NewEntry(nil).WithField("severity", "high")
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+	h.flushPending()
+
+	items := transport.data()
+	if len(items) != 1 {
+		t.Fatalf("expected 1 item, got %d", len(items))
+	}
+	if level := items[0]["level"]; level != rollbar.CRIT {
+		t.Errorf("expected %s, got %v", rollbar.CRIT, level)
+	}
+}
+
+func TestHookSetTransform(t *testing.T) {
+	h, transport := newTestHook()
+	h.SetTransform(func(data map[string]interface{}) {
+		data["custom"].(map[string]interface{})["region"] = "us"
+	})
+
+	entry := logrus.NewEntry(nil).WithField("password", "hunter2")
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	custom := transport.data()[0]["custom"].(map[string]interface{})
+	if custom["region"] != "us" {
+		t.Errorf("expected the transform to be applied, got %v", custom)
+	}
+	if custom["password"] != rollbar.FILTERED {
+		t.Errorf("expected password to be scrubbed, got %v", custom["password"])
+	}
+	if _, ok := custom[payloadKey]; ok {
+		t.Error("expected payload to be removed from the item")
+	}
+}
+
+func TestFireConcurrent(t *testing.T) {
+	h, transport := newTestHook()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry := logrus.NewEntry(nil)
+			entry.Message = "This is a test"
+			entry.Level = logrus.ErrorLevel
+			if err := h.Fire(entry); err != nil {
+				t.Error("unexpected error ", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := len(transport.data()); n != 10 {
+		t.Errorf("expected 10 reports, got %d", n)
+	}
+}
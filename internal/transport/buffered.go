@@ -3,26 +3,91 @@ package transport
 import (
 	"context"
 	"errors"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/rollbar/rollbar-go"
 )
 
 var (
-	errBufferFull = errors.New("rollbar message buffer full")
-	errClosed     = errors.New("rollbar transport closed")
+	// ErrBufferFull is returned by Send when the message buffer is full.
+	ErrBufferFull = errors.New("rollbar message buffer full")
+	// ErrClosed is returned by Send once the transport has been closed.
+	ErrClosed = errors.New("rollbar transport closed")
 )
 
+// AbandonedError is returned when a context is done before the messages
+// waited for are delivered.
+type AbandonedError struct {
+	// Count is the number of messages that were not yet delivered.
+	Count int
+	// Err is the context's error.
+	Err error
+}
+
+func (e *AbandonedError) Error() string {
+	return fmt.Sprintf("rollbar transport: %d messages abandoned: %v", e.Count, e.Err)
+}
+
+// Unwrap returns the context's error.
+func (e *AbandonedError) Unwrap() error {
+	return e.Err
+}
+
 // Buffered is an alternative to rollbar's AsyncTransport, providing
 // threadsafe and predictable message delivery built on top of the SyncTransport.
 type Buffered struct {
-	queue chan op
-	once  sync.Once
-	ctx   context.Context
+	mu      sync.Mutex
+	ops     []op
+	sends   int           // number of send ops in ops
+	bufSize int           // maximum number of send ops in ops
+	closing bool          // set once the close op has been queued
+	ready   chan struct{} // signals run that ops is not empty
+	freed   chan struct{} // closed when room is made in ops
+	sending int           // number of messages being sent or replayed
+
+	once     sync.Once
+	ctx      context.Context
+	cancel   context.CancelFunc
+	spool    *spool
+	retry    RetryPolicy
+	overflow OverflowPolicy
+	stats    stats
 
 	rollbar.Transport
 }
 
+// Option configures a Buffered transport.
+type Option func(*Buffered)
+
+// WithFailureFunc calls fn with every message that is dropped, rejected or
+// fails to send, along with the reason. fn is called from multiple goroutines
+// and must not block.
+func WithFailureFunc(fn func(body map[string]interface{}, err error)) Option {
+	return func(t *Buffered) {
+		t.stats.onFailure = fn
+	}
+}
+
+// WithOverflowPolicy decides what Send does when the buffer is full. The
+// default is DropNewest.
+func WithOverflowPolicy(p OverflowPolicy) Option {
+	return func(t *Buffered) {
+		t.overflow = p
+	}
+}
+
+// WithRetryPolicy retries failed sends according to p. Retries happen before
+// any later messages are sent, so delivery order is preserved. The inner
+// transport's own retries are disabled so attempts aren't multiplied.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(t *Buffered) {
+		t.retry = p
+		t.Transport.SetRetryAttempts(0)
+	}
+}
+
 // op represents an operation queued for transport. It is only valid
 // to set a single field in the struct to represent the operation that should
 // be performed.
@@ -30,70 +95,287 @@ type op struct {
 	send  map[string]interface{}
 	wait  chan struct{}
 	close bool
+
+	// spooled is the name the send body was persisted under, if any.
+	spooled string
 }
 
 // NewBuffered wraps the provided transport for async delivery.
-func NewBuffered(inner rollbar.Transport, bufSize int) *Buffered {
+func NewBuffered(inner rollbar.Transport, bufSize int, opts ...Option) *Buffered {
+	return newBuffered(inner, bufSize, nil, opts)
+}
+
+// NewSpooled works like NewBuffered, but persists every message to dir
+// before it is queued and removes it once it has been delivered. Messages
+// left in dir by a previous process are delivered before any new ones,
+// providing at-least-once delivery across restarts. Once the spool exceeds
+// maxSize bytes the oldest messages are discarded, as are messages older than
+// maxAge. A zero maxSize or maxAge disables that limit. Messages that can't
+// be spooled are queued in memory only.
+func NewSpooled(inner rollbar.Transport, bufSize int, dir string, maxSize int64, maxAge time.Duration, opts ...Option) (*Buffered, error) {
+	s, err := newSpool(dir, maxSize, maxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	return newBuffered(inner, bufSize, s, opts), nil
+}
+
+func newBuffered(inner rollbar.Transport, bufSize int, s *spool, opts []Option) *Buffered {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	t := &Buffered{
-		queue:     make(chan op, bufSize),
+		bufSize:   bufSize,
+		ready:     make(chan struct{}, 1),
+		freed:     make(chan struct{}),
 		ctx:       ctx,
+		cancel:    cancel,
+		spool:     s,
 		Transport: inner,
 	}
 
-	go t.run(cancel)
+	for _, o := range opts {
+		o(t)
+	}
+
+	// collect the backlog before any new messages can be spooled, so they
+	// are not delivered twice.
+	var backlog []string
+	if s != nil {
+		backlog = s.pending()
+	}
+	t.sending = len(backlog)
+
+	go t.run(backlog)
 
 	return t
 }
 
 // Send enqueues delivery of the message body to Rollbar without waiting for
-// the result. If the buffer is full, it will immediately return an error.
+// the result. If the buffer is full, the overflow policy decides whether a
+// message is dropped and ErrBufferFull returned.
 func (t *Buffered) Send(body map[string]interface{}) error {
-	select {
-	case t.queue <- op{send: body}:
-		return nil
-	case <-t.ctx.Done():
-		return errClosed
-	default:
-		return errBufferFull
+	m := op{send: body}
+	if t.spool != nil {
+		// a message that can't be spooled is still delivered, it just won't
+		// survive a restart.
+		name, err := t.spool.put(body)
+		if err != nil {
+			t.stats.spoolFailed(err)
+		}
+		m.spooled = name
+	}
+
+	evicted, err := t.enqueue(m)
+	if evicted != nil {
+		t.spool.remove(evicted.spooled)
+		t.stats.failed(evicted.send, ErrBufferFull)
+	}
+	if err != nil {
+		t.spool.remove(m.spooled)
+		t.stats.failed(body, err)
+		return err
 	}
+
+	t.stats.enqueued()
+	return nil
+}
+
+// Stats returns a snapshot of the transport's delivery counters.
+func (t *Buffered) Stats() Stats {
+	s := t.stats.snapshot()
+
+	t.mu.Lock()
+	s.QueueDepth = t.sends
+	t.mu.Unlock()
+
+	return s
 }
 
 // Wait blocks until all messages buffered before calling Wait are
 // delivered.
 func (t *Buffered) Wait() {
+	_ = t.WaitContext(context.Background())
+}
+
+// WaitContext works like Wait, but gives up once ctx is done, returning an
+// *AbandonedError. The messages remain queued.
+func (t *Buffered) WaitContext(ctx context.Context) error {
 	done := make(chan struct{})
-	select {
-	case t.queue <- op{wait: done}:
-	case <-t.ctx.Done():
-		return
+	if _, err := t.enqueue(op{wait: done}); err != nil {
+		// the transport is closing, which delivers everything anyway.
+		done = nil
 	}
 
 	select {
 	case <-done:
 	case <-t.ctx.Done():
+	case <-ctx.Done():
+		select {
+		case <-done:
+		case <-t.ctx.Done():
+		default:
+			return t.abandoned(ctx.Err())
+		}
 	}
+	return nil
 }
 
 // Close shuts down the transport and waits for queued messages to be
 // delivered.
 func (t *Buffered) Close() error {
+	return t.CloseContext(context.Background())
+}
+
+// CloseContext works like Close, but once ctx is done it stops delivering
+// messages and returns an *AbandonedError. Abandoned messages that were
+// spooled are delivered after a restart.
+func (t *Buffered) CloseContext(ctx context.Context) error {
 	t.once.Do(func() {
-		t.queue <- op{close: true}
+		t.mu.Lock()
+		defer t.mu.Unlock()
+
+		t.push(op{close: true})
+		t.closing = true
+
+		// wake up any blocked senders so they notice.
+		t.makeRoom()
 	})
 
-	<-t.ctx.Done()
-	return nil
+	select {
+	case <-t.ctx.Done():
+		return nil
+	case <-ctx.Done():
+		if t.ctx.Err() != nil {
+			return nil // closed anyway
+		}
+		err := t.abandoned(ctx.Err())
+		t.cancel()
+		return err
+	}
+}
+
+// abandoned returns an *AbandonedError for the messages not yet delivered.
+func (t *Buffered) abandoned(err error) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return &AbandonedError{Count: t.sends + t.sending, Err: err}
+}
+
+// enqueue adds m to the queue, applying the overflow policy to sends when
+// the buffer is full. It returns the op evicted to make room, if any.
+func (t *Buffered) enqueue(m op) (*op, error) {
+	var timeout <-chan time.Time
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for {
+		if t.closing {
+			return nil, ErrClosed
+		}
+
+		if m.send == nil || t.sends < t.bufSize {
+			t.push(m)
+			return nil, nil
+		}
+
+		if t.overflow.kind != block {
+			break
+		}
+
+		if timeout == nil {
+			timer := time.NewTimer(t.overflow.timeout)
+			defer timer.Stop()
+			timeout = timer.C
+		}
+
+		freed := t.freed
+		t.mu.Unlock()
+		select {
+		case <-freed:
+			t.mu.Lock()
+		case <-timeout:
+			t.mu.Lock()
+			return nil, ErrBufferFull
+		}
+	}
+
+	i := t.overflow.victim(t.ops, m)
+	if i < 0 {
+		return nil, ErrBufferFull
+	}
+
+	evicted := t.ops[i]
+	t.ops = append(t.ops[:i], t.ops[i+1:]...)
+	t.sends--
+	t.push(m)
+
+	return &evicted, nil
 }
 
-func (t *Buffered) run(cancel func()) {
-	defer cancel()
+// push appends m to the queue and signals run. The caller must hold t.mu.
+func (t *Buffered) push(m op) {
+	t.ops = append(t.ops, m)
+	if m.send != nil {
+		t.sends++
+	}
+
+	select {
+	case t.ready <- struct{}{}:
+	default:
+	}
+}
+
+// makeRoom wakes up senders blocked on a full buffer. The caller must hold
+// t.mu.
+func (t *Buffered) makeRoom() {
+	close(t.freed)
+	t.freed = make(chan struct{})
+}
+
+// next blocks until an op is queued and removes it from the queue. It
+// returns false once the transport has been abandoned.
+func (t *Buffered) next() (op, bool) {
+	for {
+		t.mu.Lock()
+		if len(t.ops) > 0 {
+			m := t.ops[0]
+			t.ops[0] = op{}
+			t.ops = t.ops[1:]
+			if m.send != nil {
+				t.sends--
+				t.sending++
+				t.makeRoom()
+			}
+			t.mu.Unlock()
+			return m, true
+		}
+		t.mu.Unlock()
+
+		select {
+		case <-t.ready:
+		case <-t.ctx.Done():
+			return op{}, false
+		}
+	}
+}
+
+func (t *Buffered) run(backlog []string) {
+	defer t.cancel()
+
+	t.replay(backlog)
+
+	for t.ctx.Err() == nil {
+		m, ok := t.next()
+		if !ok {
+			return
+		}
 
-	for m := range t.queue {
 		switch {
 		case m.send != nil:
-			_ = t.Transport.Send(m.send)
+			t.deliver(m)
 		case m.wait != nil:
 			close(m.wait)
 		case m.close:
@@ -102,3 +384,61 @@ func (t *Buffered) run(cancel func()) {
 		}
 	}
 }
+
+// deliver sends a queued message, retrying according to the retry policy and
+// removing it from the spool once it has been accepted or rejected for good.
+// Messages that still fail with a retryable error are left spooled so they
+// are retried after a restart.
+func (t *Buffered) deliver(m op) {
+	defer func() {
+		t.mu.Lock()
+		t.sending--
+		t.mu.Unlock()
+	}()
+
+	for attempt := 1; ; attempt++ {
+		err := t.Transport.Send(m.send)
+		if err == nil {
+			t.spool.remove(m.spooled)
+			t.stats.sent()
+			return
+		}
+		if !t.retry.retryable(err) {
+			t.spool.remove(m.spooled)
+			t.stats.failed(m.send, err)
+			return
+		}
+		if attempt >= t.retry.attempts() {
+			t.stats.failed(m.send, err)
+			return
+		}
+
+		timer := time.NewTimer(t.retry.delay(attempt))
+		select {
+		case <-timer.C:
+		case <-t.ctx.Done():
+			timer.Stop()
+			return
+		}
+	}
+}
+
+// replay delivers messages spooled by a previous process.
+func (t *Buffered) replay(names []string) {
+	for _, name := range names {
+		if t.ctx.Err() != nil {
+			return
+		}
+
+		body, err := t.spool.load(name)
+		if err != nil {
+			// unreadable entries will never succeed, so drop them.
+			t.mu.Lock()
+			t.sending--
+			t.mu.Unlock()
+			t.spool.remove(name)
+			continue
+		}
+		t.deliver(op{send: body, spooled: name})
+	}
+}
@@ -1,6 +1,7 @@
 package transport
 
 import (
+	"context"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -35,7 +36,7 @@ func TestBufferedTransportSend(t *testing.T) {
 		}
 	}
 
-	if lastErr != errBufferFull {
+	if lastErr != ErrBufferFull {
 		t.Fatal("send did not fill buffer")
 	}
 
@@ -55,8 +56,8 @@ func TestBufferedTransportSend(t *testing.T) {
 		}
 	}
 
-	if lastErr != errClosed {
-		t.Fatal("send after close did not return errClosed")
+	if lastErr != ErrClosed {
+		t.Fatal("send after close did not return ErrClosed")
 	}
 }
 
@@ -98,6 +99,42 @@ func TestBufferedTransportWait(t *testing.T) {
 	transport.Wait() // wait returns immediately after closed
 }
 
+func TestBufferedTransportContext(t *testing.T) {
+	inner := newGateTransport()
+	transport := NewBuffered(inner, 2)
+	data := levelBody(rollbar.ERR)
+
+	for i := 0; i < 2; i++ {
+		if err := transport.Send(data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	<-inner.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond)
+	defer cancel()
+
+	err := transport.WaitContext(ctx)
+	if e, ok := err.(*AbandonedError); !ok || e.Count != 2 || e.Err != context.DeadlineExceeded {
+		t.Fatalf("expected 2 abandoned messages, got %v", err)
+	}
+
+	err = transport.CloseContext(ctx)
+	if e, ok := err.(*AbandonedError); !ok || e.Count != 2 {
+		t.Fatalf("expected 2 abandoned messages, got %v", err)
+	}
+
+	close(inner.release)
+	transport.Wait() // returns immediately once abandoned
+
+	if len(inner.levels) != 1 {
+		t.Fatalf("expected delivery to stop after closing, got %v", inner.levels)
+	}
+	if err := transport.Send(data); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
+
 // Regression test for original issue with async rollbar client:
 //		https://github.com/rollbar/rollbar-go/issues/68#issuecomment-540308646
 func TestBufferedTransportRace(t *testing.T) {
@@ -125,7 +162,7 @@ func TestBufferedTransportRace(t *testing.T) {
 	for range iter {
 		err := transport.Send(body)
 		if err != nil {
-			if err == errBufferFull {
+			if err == ErrBufferFull {
 				time.Sleep(time.Millisecond)
 				continue
 			}
@@ -136,6 +173,7 @@ func TestBufferedTransportRace(t *testing.T) {
 
 type testTransport struct {
 	sendHook chan map[string]interface{}
+	sendErr  error
 	rollbar.Transport
 }
 
@@ -143,9 +181,11 @@ func (t *testTransport) Send(body map[string]interface{}) error {
 	if t.sendHook != nil {
 		t.sendHook <- body
 	}
-	return nil
+	return t.sendErr
 }
 
+func (t *testTransport) SetRetryAttempts(int) {}
+
 func (t *testTransport) Close() error {
 	return nil
 }
@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+type overflowKind int
+
+const (
+	dropNewest overflowKind = iota
+	dropOldest
+	dropLowestPriority
+	block
+)
+
+// OverflowPolicy decides what happens to a message sent while the buffer is
+// full.
+type OverflowPolicy struct {
+	kind    overflowKind
+	timeout time.Duration
+}
+
+var (
+	// DropNewest drops the message being sent.
+	DropNewest = OverflowPolicy{kind: dropNewest}
+
+	// DropOldest drops the oldest queued message to make room.
+	DropOldest = OverflowPolicy{kind: dropOldest}
+
+	// DropLowestPriority drops the oldest of the least severe queued messages
+	// to make room, unless the message being sent is less severe than all of
+	// them, in which case it's dropped instead.
+	DropLowestPriority = OverflowPolicy{kind: dropLowestPriority}
+)
+
+// BlockFor waits up to timeout for room in the buffer before dropping the
+// message being sent.
+func BlockFor(timeout time.Duration) OverflowPolicy {
+	return OverflowPolicy{kind: block, timeout: timeout}
+}
+
+// victim returns the index of the queued send op to drop to make room for m,
+// or -1 if m should be dropped instead.
+func (p OverflowPolicy) victim(ops []op, m op) int {
+	switch p.kind {
+	case dropOldest:
+		for i, o := range ops {
+			if o.send != nil {
+				return i
+			}
+		}
+	case dropLowestPriority:
+		victim, lowest := -1, priority(m.send)+1
+		for i, o := range ops {
+			if o.send == nil {
+				continue
+			}
+			if pri := priority(o.send); pri < lowest {
+				victim, lowest = i, pri
+			}
+		}
+		return victim
+	}
+
+	return -1
+}
+
+var priorities = map[string]int{
+	rollbar.DEBUG: 1,
+	rollbar.INFO:  2,
+	rollbar.WARN:  3,
+	rollbar.ERR:   4,
+	rollbar.CRIT:  5,
+}
+
+// priority ranks a message body by its Rollbar level. Bodies without a
+// recognized level rank lowest.
+func priority(body map[string]interface{}) int {
+	data, _ := body["data"].(map[string]interface{})
+	level, _ := data["level"].(string)
+	return priorities[level]
+}
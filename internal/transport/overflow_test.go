@@ -0,0 +1,159 @@
+package transport
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+func TestOverflowPolicies(t *testing.T) {
+	cases := []struct {
+		name      string
+		policy    OverflowPolicy
+		queued    []string
+		send      string
+		err       error
+		delivered []string
+	}{
+		{
+			name:      "drop newest",
+			policy:    DropNewest,
+			queued:    []string{rollbar.INFO, rollbar.WARN},
+			send:      rollbar.CRIT,
+			err:       ErrBufferFull,
+			delivered: []string{rollbar.INFO, rollbar.WARN},
+		},
+		{
+			name:      "drop oldest",
+			policy:    DropOldest,
+			queued:    []string{rollbar.INFO, rollbar.WARN},
+			send:      rollbar.CRIT,
+			delivered: []string{rollbar.WARN, rollbar.CRIT},
+		},
+		{
+			name:      "drop lowest priority",
+			policy:    DropLowestPriority,
+			queued:    []string{rollbar.CRIT, rollbar.INFO, rollbar.WARN},
+			send:      rollbar.ERR,
+			delivered: []string{rollbar.CRIT, rollbar.WARN, rollbar.ERR},
+		},
+		{
+			name:      "drop lowest priority keeps more severe",
+			policy:    DropLowestPriority,
+			queued:    []string{rollbar.CRIT, rollbar.ERR},
+			send:      rollbar.INFO,
+			err:       ErrBufferFull,
+			delivered: []string{rollbar.CRIT, rollbar.ERR},
+		},
+		{
+			name:      "block times out",
+			policy:    BlockFor(time.Millisecond),
+			queued:    []string{rollbar.INFO},
+			send:      rollbar.CRIT,
+			err:       ErrBufferFull,
+			delivered: []string{rollbar.INFO},
+		},
+	}
+
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			inner := newGateTransport()
+			transport := NewBuffered(inner, len(c.queued), WithOverflowPolicy(c.policy))
+
+			// hold up delivery of the first message so the buffer fills.
+			if err := transport.Send(levelBody("first")); err != nil {
+				t.Fatal(err)
+			}
+			<-inner.started
+
+			for _, level := range c.queued {
+				if err := transport.Send(levelBody(level)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := transport.Send(levelBody(c.send)); err != c.err {
+				t.Fatalf("expected %v, got %v", c.err, err)
+			}
+
+			close(inner.release)
+			transport.Close()
+
+			delivered := inner.levels[1:]
+			if len(delivered) != len(c.delivered) {
+				t.Fatalf("expected %v to be delivered, got %v", c.delivered, delivered)
+			}
+			for i := range delivered {
+				if delivered[i] != c.delivered[i] {
+					t.Fatalf("expected %v to be delivered, got %v", c.delivered, delivered)
+				}
+			}
+		})
+	}
+}
+
+func TestOverflowBlock(t *testing.T) {
+	inner := newGateTransport()
+	transport := NewBuffered(inner, 1, WithOverflowPolicy(BlockFor(time.Minute)))
+
+	if err := transport.Send(levelBody(rollbar.INFO)); err != nil {
+		t.Fatal(err)
+	}
+	<-inner.started
+	if err := transport.Send(levelBody(rollbar.INFO)); err != nil {
+		t.Fatal(err)
+	}
+
+	sent := make(chan error)
+	go func() {
+		sent <- transport.Send(levelBody(rollbar.CRIT))
+	}()
+
+	select {
+	case <-sent:
+		t.Fatal("send returned while the buffer was full")
+	case <-time.After(10 * time.Millisecond):
+	}
+
+	close(inner.release)
+	if err := <-sent; err != nil {
+		t.Fatal(err)
+	}
+	transport.Close()
+
+	if len(inner.levels) != 3 {
+		t.Fatalf("expected all messages to be delivered, got %v", inner.levels)
+	}
+}
+
+func levelBody(level string) map[string]interface{} {
+	return map[string]interface{}{
+		"data": map[string]interface{}{"level": level},
+	}
+}
+
+// gateTransport signals started when the first message is sent and holds up
+// delivery until release is closed.
+type gateTransport struct {
+	testTransport
+	started chan struct{}
+	release chan struct{}
+	levels  []string
+}
+
+func newGateTransport() *gateTransport {
+	return &gateTransport{
+		started: make(chan struct{}),
+		release: make(chan struct{}),
+	}
+}
+
+func (t *gateTransport) Send(body map[string]interface{}) error {
+	t.levels = append(t.levels, body["data"].(map[string]interface{})["level"].(string))
+	if len(t.levels) == 1 {
+		close(t.started)
+	}
+	<-t.release
+	return nil
+}
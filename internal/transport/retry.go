@@ -0,0 +1,93 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+// RetryPolicy controls how messages that the inner transport failed to send
+// are retried.
+type RetryPolicy struct {
+	// MaxAttempts is the number of times a message is sent before giving up,
+	// including the first attempt. Values below 1 are treated as 1.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. It doubles with every
+	// following retry.
+	BaseDelay time.Duration
+
+	// MaxDelay caps the delay between retries. Zero means no cap.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of each delay, between 0 and 1, that is
+	// randomized to spread out retries from many processes.
+	Jitter float64
+
+	// Retryable reports whether a failed send is worth retrying. If nil,
+	// DefaultRetryable is used.
+	Retryable func(error) bool
+}
+
+// DefaultRetryable retries rate limiting, server errors and network errors,
+// but not other API responses or payloads that can't be encoded.
+func DefaultRetryable(err error) bool {
+	var status rollbar.ErrHTTPError
+	if errors.As(err, &status) {
+		return status == http.StatusTooManyRequests || status >= 500
+	}
+
+	var unsupportedType *json.UnsupportedTypeError
+	var unsupportedValue *json.UnsupportedValueError
+	var marshaler *json.MarshalerError
+	if errors.As(err, &unsupportedType) || errors.As(err, &unsupportedValue) || errors.As(err, &marshaler) {
+		return false
+	}
+
+	return true
+}
+
+func (p RetryPolicy) attempts() int {
+	if p.MaxAttempts < 1 {
+		return 1
+	}
+	return p.MaxAttempts
+}
+
+func (p RetryPolicy) retryable(err error) bool {
+	if p.Retryable == nil {
+		return DefaultRetryable(err)
+	}
+	return p.Retryable(err)
+}
+
+// delay returns how long to wait before the given retry, starting at 1.
+func (p RetryPolicy) delay(retry int) time.Duration {
+	d := p.BaseDelay
+	for i := 1; i < retry && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		if d > math.MaxInt64/2 {
+			d = math.MaxInt64
+			break
+		}
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+
+	if p.Jitter > 0 {
+		j := p.Jitter
+		if j > 1 {
+			j = 1
+		}
+		// jitter doesn't need a secure source of randomness.
+		d -= time.Duration(j * rand.Float64() * float64(d)) // nolint:gosec
+	}
+
+	return d
+}
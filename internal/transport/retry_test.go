@@ -0,0 +1,101 @@
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	p := RetryPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, want := range expected {
+		if got := p.delay(i + 1); got != want {
+			t.Errorf("delay(%d) = %s, want %s", i+1, got, want)
+		}
+	}
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		if d := p.delay(2); d < time.Second || d > 2*time.Second {
+			t.Fatalf("jittered delay %s outside of [1s, 2s]", d)
+		}
+	}
+
+	// large retry counts don't overflow.
+	p = RetryPolicy{BaseDelay: time.Second, MaxDelay: math.MaxInt64}
+	if d := p.delay(100); d <= 0 {
+		t.Fatalf("expected a positive delay, got %s", d)
+	}
+}
+
+func TestDefaultRetryable(t *testing.T) {
+	cases := []struct {
+		err   error
+		retry bool
+	}{
+		{rollbar.ErrHTTPError(429), true},
+		{rollbar.ErrHTTPError(503), true},
+		{rollbar.ErrHTTPError(400), false},
+		{&json.UnsupportedTypeError{}, false},
+		{io.EOF, true},
+	}
+
+	for _, c := range cases {
+		if got := DefaultRetryable(c.err); got != c.retry {
+			t.Errorf("DefaultRetryable(%v) = %t, want %t", c.err, got, c.retry)
+		}
+	}
+}
+
+func TestBufferedTransportRetry(t *testing.T) {
+	inner := &countingTransport{failures: 2, err: rollbar.ErrHTTPError(500)}
+	transport := NewBuffered(inner, 1, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+	if err := transport.Send(map[string]interface{}{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	transport.Wait()
+
+	if inner.sends != 3 {
+		t.Fatalf("expected 3 attempts, got %d", inner.sends)
+	}
+
+	// non-retryable errors are attempted once.
+	inner = &countingTransport{failures: 2, err: errors.New("nope")}
+	transport = NewBuffered(inner, 1, WithRetryPolicy(RetryPolicy{
+		MaxAttempts: 3,
+		Retryable:   func(error) bool { return false },
+	}))
+
+	if err := transport.Send(map[string]interface{}{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	transport.Close()
+
+	if inner.sends != 1 {
+		t.Fatalf("expected 1 attempt, got %d", inner.sends)
+	}
+}
+
+// countingTransport fails the first failures sends with err.
+type countingTransport struct {
+	testTransport
+	failures int
+	err      error
+	sends    int
+}
+
+func (t *countingTransport) Send(body map[string]interface{}) error {
+	t.sends++
+	if t.sends <= t.failures {
+		return t.err
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+package transport
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const spoolExt = ".json"
+
+// spool persists message bodies to a directory so that messages queued for
+// delivery survive a process restart.
+type spool struct {
+	dir     string
+	maxSize int64
+	maxAge  time.Duration
+
+	mu      sync.Mutex
+	seq     uint64
+	entries []spoolEntry // oldest first
+	size    int64
+}
+
+// spoolEntry is a spooled body tracked in memory, so the directory needn't
+// be scanned every time a body is spooled.
+type spoolEntry struct {
+	name    string
+	size    int64
+	modTime time.Time
+}
+
+// newSpool creates dir if needed and returns a spool backed by it. A maxSize
+// or maxAge of zero disables the respective limit.
+func newSpool(dir string, maxSize int64, maxAge time.Duration) (*spool, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+
+	return &spool{
+		dir:     dir,
+		maxSize: maxSize,
+		maxAge:  maxAge,
+	}, nil
+}
+
+// put writes body to the spool and returns the name it was stored under.
+// Older entries are evicted if the spool grows beyond its limits.
+func (s *spool) put(body map[string]interface{}) (string, error) {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%010d%s", time.Now().UnixNano(), s.seq, spoolExt)
+	path := filepath.Join(s.dir, name)
+
+	// write to a temporary file first so a partially written entry is never
+	// replayed.
+	tmp := path + ".tmp"
+	if err := ioutil.WriteFile(tmp, b, 0600); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return "", err
+	}
+
+	now := time.Now()
+	s.entries = append(s.entries, spoolEntry{name: name, size: int64(len(b)), modTime: now})
+	s.size += int64(len(b))
+	s.evict(now)
+	return name, nil
+}
+
+// load reads a previously spooled body.
+func (s *spool) load(name string) (map[string]interface{}, error) {
+	b, err := ioutil.ReadFile(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal(b, &body); err != nil {
+		return nil, err
+	}
+	return body, nil
+}
+
+// remove deletes a spooled body once it no longer needs to be delivered. It is
+// a no-op on a nil spool.
+func (s *spool) remove(name string) {
+	if s == nil || name == "" {
+		return
+	}
+	_ = os.Remove(filepath.Join(s.dir, name))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// bodies are mostly delivered in order, so the entry is usually first.
+	for i, e := range s.entries {
+		if e.name == name {
+			s.size -= e.size
+			s.entries = append(s.entries[:i:i], s.entries[i+1:]...)
+			break
+		}
+	}
+}
+
+// pending returns the names of all spooled bodies, oldest first, after
+// evicting any that exceed the age or size limits. It scans the directory,
+// so it is only called when the spool is opened.
+func (s *spool) pending() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.scan()
+	names := make([]string, 0, len(s.entries))
+	for _, e := range s.entries {
+		names = append(names, e.name)
+	}
+	return names
+}
+
+// scan rebuilds the in-memory entries from the directory and evicts those
+// exceeding the limits. The caller must hold s.mu.
+func (s *spool) scan() {
+	infos, err := ioutil.ReadDir(s.dir)
+	if err != nil {
+		return
+	}
+
+	now := time.Now()
+	s.entries = s.entries[:0]
+	s.size = 0
+	for _, fi := range infos {
+		if fi.IsDir() || !strings.HasSuffix(fi.Name(), spoolExt) {
+			continue
+		}
+		if s.maxAge > 0 && now.Sub(fi.ModTime()) > s.maxAge {
+			_ = os.Remove(filepath.Join(s.dir, fi.Name()))
+			continue
+		}
+		s.entries = append(s.entries, spoolEntry{name: fi.Name(), size: fi.Size(), modTime: fi.ModTime()})
+		s.size += fi.Size()
+	}
+
+	// names begin with a fixed width timestamp, so they sort oldest first.
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.entries[i].name < s.entries[j].name
+	})
+
+	s.evict(now)
+}
+
+// evict removes expired entries and then the oldest entries until the spool
+// fits within maxSize. Entries are kept oldest first, so only the first needs
+// checking at each step. The caller must hold s.mu.
+func (s *spool) evict(now time.Time) {
+	for len(s.entries) > 0 {
+		e := s.entries[0]
+		expired := s.maxAge > 0 && now.Sub(e.modTime) > s.maxAge
+		if !expired && (s.maxSize <= 0 || s.size <= s.maxSize) {
+			return
+		}
+		_ = os.Remove(filepath.Join(s.dir, e.name))
+		s.size -= e.size
+		s.entries = s.entries[1:]
+	}
+}
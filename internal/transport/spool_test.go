@@ -0,0 +1,199 @@
+package transport
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+func tempSpoolDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "rollrus-spool")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestSpooledTransportReplay(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	// the first transport can't deliver, so the message stays spooled.
+	failing := &testTransport{sendErr: errors.New("boom")}
+	transport, err := NewSpooled(failing, 1, dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Send(map[string]interface{}{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	transport.Close()
+
+	if n := len(transport.spool.pending()); n != 1 {
+		t.Fatalf("expected 1 spooled message, got %d", n)
+	}
+
+	// the next transport replays it.
+	inner := &testTransport{sendHook: make(chan map[string]interface{}, 1)}
+	transport, err = NewSpooled(inner, 1, dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport.Wait()
+
+	select {
+	case recv := <-inner.sendHook:
+		if recv["a"] != "b" {
+			t.Errorf("transport replayed %v, want a=b", recv)
+		}
+	default:
+		t.Fatal("spooled message was not replayed")
+	}
+
+	transport.Close()
+	if n := len(transport.spool.pending()); n != 0 {
+		t.Fatalf("expected delivered message to be removed, %d remain", n)
+	}
+}
+
+func TestSpooledTransportRejected(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	// the message is rejected for good, so it isn't kept for a restart.
+	rejecting := &testTransport{sendErr: rollbar.ErrHTTPError(http.StatusUnprocessableEntity)}
+	transport, err := NewSpooled(rejecting, 1, dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Send(map[string]interface{}{"a": "b"}); err != nil {
+		t.Fatal(err)
+	}
+	transport.Close()
+
+	if n := len(transport.spool.pending()); n != 0 {
+		t.Fatalf("expected rejected message to be removed, %d remain", n)
+	}
+}
+
+func TestSpooledTransportSpoolFailure(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	inner := &testTransport{sendHook: make(chan map[string]interface{}, 1)}
+	transport, err := NewSpooled(inner, 1, dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without its directory the spool can't be written to.
+	if err := os.RemoveAll(dir); err != nil {
+		t.Fatal(err)
+	}
+	if err := transport.Send(map[string]interface{}{"a": "b"}); err != nil {
+		t.Fatalf("expected the message to be queued in memory, got %v", err)
+	}
+	transport.Close()
+
+	select {
+	case recv := <-inner.sendHook:
+		if recv["a"] != "b" {
+			t.Errorf("transport sent %v, want a=b", recv)
+		}
+	default:
+		t.Fatal("message was not delivered")
+	}
+
+	s := transport.Stats()
+	if s.SpoolFailed != 1 || s.Failed != 0 || s.Sent != 1 || s.LastError == nil {
+		t.Errorf("expected a spool failure and a sent message, got %+v", s)
+	}
+}
+
+func TestSpoolMaxSize(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 30, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last string
+	for i := 0; i < 5; i++ {
+		if last, err = s.put(map[string]interface{}{"a": "b"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// each entry is 9 bytes, so only the newest 3 fit.
+	pending := s.pending()
+	if len(pending) != 3 {
+		t.Fatalf("expected 3 spooled messages, got %d", len(pending))
+	}
+	if pending[2] != last {
+		t.Errorf("expected newest message %q to be kept, got %v", last, pending)
+	}
+}
+
+func TestSpoolMaxAge(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 0, time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name, err := s.put(map[string]interface{}{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.put(map[string]interface{}{"c": "d"}); err != nil {
+		t.Fatal(err)
+	}
+
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(dir, name), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := len(s.pending()); n != 1 {
+		t.Fatalf("expected expired message to be discarded, %d remain", n)
+	}
+}
+
+func TestSpoolTracksEntries(t *testing.T) {
+	dir := tempSpoolDir(t)
+	defer os.RemoveAll(dir)
+
+	s, err := newSpool(dir, 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := s.put(map[string]interface{}{"a": "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.put(map[string]interface{}{"c": "d"}); err != nil {
+		t.Fatal(err)
+	}
+	s.remove(first)
+
+	// each entry is 9 bytes.
+	if len(s.entries) != 1 || s.size != 9 {
+		t.Fatalf("expected 1 entry of 9 bytes, got %d entries of %d bytes", len(s.entries), s.size)
+	}
+
+	// the in-memory entries match the directory.
+	if pending := s.pending(); len(pending) != 1 || s.size != 9 {
+		t.Fatalf("expected 1 entry of 9 bytes after a scan, got %v of %d bytes", pending, s.size)
+	}
+}
@@ -0,0 +1,90 @@
+package transport
+
+import (
+	"sync"
+	"time"
+)
+
+// Stats is a snapshot of a Buffered transport's delivery counters.
+type Stats struct {
+	// Enqueued is the number of messages accepted for delivery.
+	Enqueued uint64
+	// Dropped is the number of messages rejected because the buffer was full.
+	Dropped uint64
+	// Rejected is the number of messages rejected because the transport was
+	// closed.
+	Rejected uint64
+	// Sent is the number of messages delivered successfully.
+	Sent uint64
+	// Failed is the number of messages that could not be delivered.
+	Failed uint64
+	// SpoolFailed is the number of messages that could not be spooled, and
+	// were queued in memory only.
+	SpoolFailed uint64
+
+	// QueueDepth is the number of messages waiting to be sent.
+	QueueDepth int
+
+	// LastError is the most recent error, and LastErrorAt when it happened.
+	LastError   error
+	LastErrorAt time.Time
+	// LastSuccessAt is when a message was last delivered successfully.
+	LastSuccessAt time.Time
+}
+
+// stats tracks delivery counters and notifies onFailure of messages that
+// weren't delivered.
+type stats struct {
+	mu        sync.Mutex
+	s         Stats
+	onFailure func(body map[string]interface{}, err error)
+}
+
+func (st *stats) snapshot() Stats {
+	st.mu.Lock()
+	defer st.mu.Unlock()
+	return st.s
+}
+
+func (st *stats) enqueued() {
+	st.mu.Lock()
+	st.s.Enqueued++
+	st.mu.Unlock()
+}
+
+func (st *stats) sent() {
+	st.mu.Lock()
+	st.s.Sent++
+	st.s.LastSuccessAt = time.Now()
+	st.mu.Unlock()
+}
+
+// spoolFailed records that a message could not be spooled because of err.
+// The message is still queued, so onFailure is not notified.
+func (st *stats) spoolFailed(err error) {
+	st.mu.Lock()
+	st.s.SpoolFailed++
+	st.s.LastError = err
+	st.s.LastErrorAt = time.Now()
+	st.mu.Unlock()
+}
+
+// failed records that body wasn't delivered because of err.
+func (st *stats) failed(body map[string]interface{}, err error) {
+	st.mu.Lock()
+	switch err {
+	case ErrBufferFull:
+		st.s.Dropped++
+	case ErrClosed:
+		st.s.Rejected++
+	default:
+		st.s.Failed++
+	}
+	st.s.LastError = err
+	st.s.LastErrorAt = time.Now()
+	st.mu.Unlock()
+
+	if st.onFailure != nil {
+		st.onFailure(body, err)
+	}
+}
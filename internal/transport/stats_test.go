@@ -0,0 +1,64 @@
+package transport
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+func TestBufferedTransportStats(t *testing.T) {
+	var mu sync.Mutex
+	var failures []error
+	onFailure := func(body map[string]interface{}, err error) {
+		mu.Lock()
+		failures = append(failures, err)
+		mu.Unlock()
+	}
+
+	inner := &testTransport{sendHook: make(chan map[string]interface{})}
+	transport := NewBuffered(inner, 1, WithFailureFunc(onFailure))
+	data := map[string]interface{}{"a": "b"}
+
+	for transport.Send(data) != ErrBufferFull {
+	}
+
+	s := transport.Stats()
+	if s.Dropped != 1 || s.LastError != ErrBufferFull || s.LastErrorAt.IsZero() {
+		t.Errorf("expected a dropped message, got %+v", s)
+	}
+
+	for i := uint64(0); i < s.Enqueued; i++ {
+		<-inner.sendHook
+	}
+	transport.Close()
+
+	if err := transport.Send(data); err != ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+
+	s = transport.Stats()
+	if s.Sent != s.Enqueued || s.Rejected != 1 || s.QueueDepth != 0 || s.LastSuccessAt.IsZero() {
+		t.Errorf("unexpected stats %+v", s)
+	}
+
+	// failed sends are counted once all attempts are exhausted.
+	sendErr := errors.New("boom")
+	transport = NewBuffered(&testTransport{sendErr: sendErr}, 1, WithFailureFunc(onFailure))
+	if err := transport.Send(data); err != nil {
+		t.Fatal(err)
+	}
+	transport.Close()
+
+	s = transport.Stats()
+	if s.Failed != 1 || s.LastError != sendErr {
+		t.Errorf("expected a failed message, got %+v", s)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	expected := []error{ErrBufferFull, ErrClosed, sendErr}
+	if !reflect.DeepEqual(failures, expected) {
+		t.Errorf("expected failure func to be called with %v, got %v", expected, failures)
+	}
+}
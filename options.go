@@ -1,6 +1,15 @@
 package rollrus
 
-import "github.com/sirupsen/logrus"
+import (
+	"context"
+	"reflect"
+	"text/template"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/rollrus/internal/transport"
+)
 
 // OptionFunc that can be passed to NewHook.
 type OptionFunc func(*Hook)
@@ -29,13 +38,41 @@ func WithMinLevel(level logrus.Level) OptionFunc {
 }
 
 // WithIgnoredErrors is an OptionFunc that whitelists certain errors to prevent
-// them from firing. See https://golang.org/ref/spec#Comparison_operators
+// them from firing. An error is ignored if errors.Is matches it against any
+// of them, following both Unwrap and pkg/errors style Cause chains.
 func WithIgnoredErrors(errors ...error) OptionFunc {
 	return func(h *Hook) {
 		h.ignoredErrors = append(h.ignoredErrors, errors...)
 	}
 }
 
+// WithIgnoredErrorTypes is an OptionFunc that prevents errors of certain types
+// from firing. Each target is a pointer to an error type or interface, as
+// would be passed to errors.As, such as new(*net.OpError). An error is
+// ignored if errors.As finds a target type in its Unwrap or Cause chain. It
+// panics if a target isn't a pointer to a type implementing error or to an
+// interface.
+func WithIgnoredErrorTypes(targets ...interface{}) OptionFunc {
+	types := make([]reflect.Type, 0, len(targets))
+	for _, target := range targets {
+		typ := reflect.TypeOf(target)
+		if typ == nil || typ.Kind() != reflect.Ptr {
+			panic("rollrus: WithIgnoredErrorTypes target must be a pointer")
+		}
+		elem := typ.Elem()
+		if elem.Kind() != reflect.Interface && !elem.Implements(errorType) {
+			panic("rollrus: WithIgnoredErrorTypes target must point to an interface or a type implementing error")
+		}
+		types = append(types, elem)
+	}
+
+	return func(h *Hook) {
+		h.ignoredTypes = append(h.ignoredTypes, types...)
+	}
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
 // WithIgnoreErrorFunc is an OptionFunc that receives the error that is about
 // to be logged and returns true/false if it wants to fire a Rollbar alert for.
 func WithIgnoreErrorFunc(fn func(error) bool) OptionFunc {
@@ -51,3 +88,244 @@ func WithIgnoreFunc(fn func(err error, fields map[string]interface{}) bool) Opti
 		h.ignoreFunc = fn
 	}
 }
+
+// WithSpool is an OptionFunc that persists reports to dir until they have
+// been delivered. Reports still queued when the process exits are delivered
+// by the next hook created with the same dir. Once the spool holds more than
+// maxSize bytes the oldest reports are discarded, as are reports older than
+// maxAge. A zero maxSize or maxAge disables that limit. If dir can't be
+// created, reports are buffered in memory as usual.
+func WithSpool(dir string, maxSize int64, maxAge time.Duration) OptionFunc {
+	return func(h *Hook) {
+		h.spoolDir = dir
+		h.spoolMaxSize = maxSize
+		h.spoolMaxAge = maxAge
+	}
+}
+
+// RetryPolicy controls how reports that failed to send are retried. A nil
+// Retryable retries rate limiting, server errors and network errors.
+type RetryPolicy = transport.RetryPolicy
+
+// WithRetryPolicy is an OptionFunc that retries reports that failed to send
+// with exponential backoff according to p. Reports are still delivered in
+// the order they were logged.
+func WithRetryPolicy(p RetryPolicy) OptionFunc {
+	return func(h *Hook) {
+		h.transportOpts = append(h.transportOpts, transport.WithRetryPolicy(p))
+	}
+}
+
+// WithDeliveryFailureFunc is an OptionFunc that calls fn with the body of
+// every report that is dropped, rejected or fails to send, along with the
+// reason, such as ErrBufferFull. fn is called from multiple goroutines and
+// must not block.
+func WithDeliveryFailureFunc(fn func(body map[string]interface{}, err error)) OptionFunc {
+	return func(h *Hook) {
+		h.transportOpts = append(h.transportOpts, transport.WithFailureFunc(fn))
+	}
+}
+
+// OverflowPolicy decides what happens to a report when too many reports are
+// waiting to be sent.
+type OverflowPolicy = transport.OverflowPolicy
+
+var (
+	// DropNewest drops the report being made. This is the default.
+	DropNewest = transport.DropNewest
+
+	// DropOldest drops the oldest waiting report to make room.
+	DropOldest = transport.DropOldest
+
+	// DropLowestPriority drops the oldest of the least severe waiting reports
+	// to make room, so that CRIT reports are kept over WARN or INFO reports.
+	// If the report being made is less severe than all waiting reports, it's
+	// dropped instead.
+	DropLowestPriority = transport.DropLowestPriority
+)
+
+// BlockFor is an OverflowPolicy that waits up to timeout for room before
+// dropping the report being made. Logging blocks while it waits.
+func BlockFor(timeout time.Duration) OverflowPolicy {
+	return transport.BlockFor(timeout)
+}
+
+// WithOverflowPolicy is an OptionFunc that decides what happens to reports
+// when too many are waiting to be sent.
+func WithOverflowPolicy(p OverflowPolicy) OptionFunc {
+	return func(h *Hook) {
+		h.transportOpts = append(h.transportOpts, transport.WithOverflowPolicy(p))
+	}
+}
+
+// WithFlushTimeout is an OptionFunc that bounds how long Fatal and Panic
+// entries wait for pending reports to be delivered before the process exits.
+// The default is 10 seconds; zero waits indefinitely.
+func WithFlushTimeout(timeout time.Duration) OptionFunc {
+	return func(h *Hook) {
+		h.flushTimeout = timeout
+	}
+}
+
+// WithRateLimit is an OptionFunc that suppresses reports beyond the limits
+// in l, making a report with the number suppressed instead once
+// l.SummaryInterval has passed since the first was suppressed.
+// Fatal and Panic entries are never suppressed, except for recovered panics.
+func WithRateLimit(l RateLimit) OptionFunc {
+	return func(h *Hook) {
+		h.limiter = newRateLimiter(l, h.reportSuppressed)
+	}
+}
+
+// WithDeduplication is an OptionFunc that collapses identical errors, with
+// the same cause logged from the same place, into a single report made once
+// window has passed since the first of them. The report carries the number of
+// occurrences and when the first and last were logged. Fatal and Panic
+// entries, except for recovered panics, are reported right away, along with
+// anything held back.
+func WithDeduplication(window time.Duration) OptionFunc {
+	return func(h *Hook) {
+		h.deduper = newDeduper(window, h.reportOccurrences)
+	}
+}
+
+// WithSampling is an OptionFunc that only reports a proportion of entries at
+// each level, as configured by s. Reports made from sampled entries carry the
+// rate they were sampled at in the sample_rate field, so that the actual
+// number of entries can be estimated.
+func WithSampling(s Sampling) OptionFunc {
+	return func(h *Hook) {
+		h.sampling = &s
+	}
+}
+
+// WithContextExtractor is an OptionFunc that reports request scoped data,
+// such as the affected person or trace IDs, that fn finds in the context of
+// entries logged with WithContext. The data is reported in the item's person,
+// request and custom sections rather than as fields.
+func WithContextExtractor(fn ContextExtractor) OptionFunc {
+	return func(h *Hook) {
+		h.contextExtractor = fn
+	}
+}
+
+// WithPersonFields is an OptionFunc that reports the person affected by an
+// entry from its fields, named id, username and email, rather than as custom
+// data. An empty name isn't mapped. Entries without the id field aren't
+// reported with a person, as Rollbar requires an id.
+func WithPersonFields(id, username, email string) OptionFunc {
+	return func(h *Hook) {
+		h.personFields = &personFields{id: id, username: username, email: email}
+	}
+}
+
+// WithRequestField is an OptionFunc that reports the *http.Request held by
+// the named field in the item's request section. The request and req fields,
+// followed by any other field holding an *http.Request, are checked when it
+// doesn't hold one. Fields holding a request are never reported as custom
+// data.
+func WithRequestField(name string) OptionFunc {
+	return func(h *Hook) {
+		h.requestFields = append(h.requestFields, name)
+	}
+}
+
+// WithStructuredFields is an OptionFunc that reports fields as their native
+// JSON types rather than as strings, so that Rollbar can filter and group on
+// them. Maps, slices and structs are converted recursively up to maxDepth
+// levels deep, keeping at most maxSize elements of each. Values that can't
+// be serialized, or are nested too deep, are reported as strings. Zero limits
+// default to a depth of 5 and a size of 100.
+func WithStructuredFields(maxDepth, maxSize int) OptionFunc {
+	if maxDepth <= 0 {
+		maxDepth = defaultMaxFieldDepth
+	}
+	if maxSize <= 0 {
+		maxSize = defaultMaxFieldSize
+	}
+
+	return func(h *Hook) {
+		h.fields = &fieldConverter{maxDepth: maxDepth, maxSize: maxSize}
+	}
+}
+
+// WithScrubber is an OptionFunc that adds s to the scrubbers redacting
+// sensitive data from reports. Scrubbers are applied in the order they're
+// added, after DefaultScrubber.
+func WithScrubber(s Scrubber) OptionFunc {
+	return func(h *Hook) {
+		h.customScrubbers = append(h.customScrubbers, s)
+	}
+}
+
+// WithoutDefaultScrubber is an OptionFunc that stops DefaultScrubber from
+// being applied to reports. Scrubbers added with WithScrubber still apply.
+func WithoutDefaultScrubber() OptionFunc {
+	return func(h *Hook) {
+		h.noDefaultScrubber = true
+	}
+}
+
+// WithMaxPayloadSize is an OptionFunc that truncates reports to fit within
+// size bytes of JSON, as Rollbar rejects items that are too large. Long
+// strings are shortened first, then long stack traces are trimmed and finally
+// the largest fields are dropped. What was truncated is recorded in the
+// truncated field. The default is 512KiB; zero disables truncation. Fields
+// that can't be encoded as JSON are dropped either way.
+func WithMaxPayloadSize(size int) OptionFunc {
+	return func(h *Hook) {
+		h.maxPayloadSize = size
+	}
+}
+
+// WithFingerprint is an OptionFunc that groups reports into Rollbar items by
+// the fingerprint fn returns, rather than by Rollbar's default grouping. fn is
+// given the entry, the error extracted from it and its converted fields. An
+// empty fingerprint leaves the report to the default grouping.
+// NormalizedFingerprint groups errors that embed IDs in their messages.
+func WithFingerprint(fn func(*logrus.Entry, error, map[string]interface{}) string) OptionFunc {
+	return func(h *Hook) {
+		h.fingerprintFunc = fn
+	}
+}
+
+// WithTitleTemplate is an OptionFunc that titles Rollbar items by executing
+// tmpl with the entry's TitleData, rather than with the error or message.
+// For example:
+//
+//	template.Must(template.New("title").Parse(`[{{.Fields.service}}] {{.Message}}: {{.Fields.reason}}`))
+//
+// Reports are titled as usual if tmpl fails or renders an empty title.
+func WithTitleTemplate(tmpl *template.Template) OptionFunc {
+	return func(h *Hook) {
+		h.titleTemplate = tmpl
+	}
+}
+
+// WithLevelMapper is an OptionFunc that decides the Rollbar level entries
+// are reported at, such as rollbar.CRIT, rather than DefaultLevel. An empty
+// level leaves the entry at its default level. Entries are still reported
+// as errors or messages depending on their logrus level.
+func WithLevelMapper(fn func(*logrus.Entry) string) OptionFunc {
+	return func(h *Hook) {
+		h.levelMapper = fn
+	}
+}
+
+// WithRecoverPanics is an OptionFunc that recovers panics in goroutines
+// started by Hook.Go and Hook.GoContext once they're reported, rather than
+// re-panicking and crashing the program.
+func WithRecoverPanics() OptionFunc {
+	return func(h *Hook) {
+		h.recoverPanics = true
+	}
+}
+
+// WithPanicFunc is an OptionFunc that calls fn with the context and
+// recovered value of panics in goroutines started by Hook.Go and
+// Hook.GoContext, once they're reported.
+func WithPanicFunc(fn func(ctx context.Context, v interface{})) OptionFunc {
+	return func(h *Hook) {
+		h.panicFunc = fn
+	}
+}
@@ -0,0 +1,132 @@
+package rollrus
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+// panicError is reported for recovered panic values that aren't errors.
+type panicError struct {
+	value interface{}
+}
+
+func (e *panicError) Error() string {
+	return fmt.Sprintf("panic: %v", e.value)
+}
+
+// panicValueError returns the error reported for a recovered panic value.
+// Errors are reported as they are, so their type and chain are preserved.
+func panicValueError(v interface{}) error {
+	if err, ok := v.(error); ok {
+		return err
+	}
+	return &panicError{value: v}
+}
+
+// ReportPanic reports a panic to Rollbar with the stack trace of the
+// panicking goroutine, using the hook's options, and then re-panics. It must
+// be deferred directly:
+//
+//	defer hook.ReportPanic()
+func (r *Hook) ReportPanic() {
+	if p := recover(); p != nil {
+		defer panic(p)
+		r.reportPanic(context.Background(), p, nil, &payload{stack: panicStack()})
+	}
+}
+
+// ReportRecovered reports v, a value recovered from a panic, like a Panic
+// entry logged with ctx and fields, without re-panicking. It must be called
+// from the deferred function that recovered v, so that the stack trace of the
+// panic can be reported.
+func (r *Hook) ReportRecovered(ctx context.Context, v interface{}, fields logrus.Fields) {
+	r.reportPanic(ctx, v, fields, &payload{stack: panicStack(), recovered: true})
+}
+
+// reportPanic reports a recovered panic value as a Panic entry logged with
+// ctx and fields, applying p to the report.
+func (r *Hook) reportPanic(ctx context.Context, v interface{}, fields logrus.Fields, p *payload) {
+	err := panicValueError(v)
+	data := make(logrus.Fields, len(fields)+1)
+	for k, v := range fields {
+		data[k] = v
+	}
+	data[logrus.ErrorKey] = err
+
+	entry := &logrus.Entry{
+		Data:    data,
+		Time:    time.Now(),
+		Level:   logrus.PanicLevel,
+		Message: err.Error(),
+		Context: ctx,
+	}
+	_ = r.fire(entry, p)
+}
+
+// panicStack returns the stack trace of the panicking goroutine, when called
+// from a deferred function while panicking. It starts at the frame that
+// panicked, skipping the deferred calls and the runtime's panic handling.
+func panicStack() rollbar.Stack {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var all []runtime.Frame
+	start := 0
+	for {
+		frame, more := frames.Next()
+		all = append(all, frame)
+		if frame.Function == "runtime.gopanic" {
+			start = len(all)
+		} else if start == len(all)-1 && strings.HasPrefix(frame.Function, "runtime.") {
+			// runtime errors panic from within the runtime.
+			start = len(all)
+		}
+		if !more {
+			break
+		}
+	}
+
+	return buildStack(all[start:])
+}
+
+// Go runs fn in a new goroutine, reporting it if it panics. See GoContext.
+func (r *Hook) Go(fn func()) {
+	r.GoContext(context.Background(), func(context.Context) {
+		fn()
+	})
+}
+
+// GoContext runs fn with ctx in a new goroutine. If fn panics, the panic is
+// reported like a Panic entry logged with ctx, with the stack trace of the
+// panic. The func given to WithPanicFunc is then called, and the panic is
+// re-panicked unless WithRecoverPanics is used.
+func (r *Hook) GoContext(ctx context.Context, fn func(context.Context)) {
+	go func() {
+		defer r.recoverGo(ctx)
+		fn(ctx)
+	}()
+}
+
+// recoverGo reports a panic in a goroutine started by GoContext. It must be
+// deferred directly.
+func (r *Hook) recoverGo(ctx context.Context) {
+	p := recover()
+	if p == nil {
+		return
+	}
+
+	r.reportPanic(ctx, p, nil, &payload{stack: panicStack(), recovered: r.recoverPanics})
+	if r.panicFunc != nil {
+		r.panicFunc(ctx, p)
+	}
+	if !r.recoverPanics {
+		panic(p)
+	}
+}
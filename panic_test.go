@@ -0,0 +1,176 @@
+package rollrus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+func panicWith(v interface{}) {
+	panic(v)
+}
+
+func panicOnNilMap() {
+	var m map[string]int
+	m["boom"] = 1
+}
+
+// recoverReportPanic calls fn with h.ReportPanic deferred, returning the
+// value it re-panics with.
+func recoverReportPanic(h *Hook, fn func()) (p interface{}) {
+	defer func() {
+		p = recover()
+	}()
+	defer h.ReportPanic()
+	fn()
+	return nil
+}
+
+func TestHookReportPanic(t *testing.T) {
+	wrapped := fmt.Errorf("reading: %w", io.EOF)
+
+	cases := []struct {
+		name    string
+		fn      func()
+		class   string
+		message string
+		method  string
+		chain   int
+	}{
+		{"value", func() { panicWith("boom") }, "panic(string)", "panic: boom", "rollrus.panicWith", 1},
+		{"error", func() { panicWith(wrapped) }, "fmt.wrapError", "reading: EOF", "rollrus.panicWith", 2},
+		{"runtime", panicOnNilMap, "runtime.plainError", "assignment to entry in nil map", "rollrus.panicOnNilMap", 1},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			h, transport := newTestHook()
+
+			if p := recoverReportPanic(h, c.fn); p == nil {
+				t.Fatal("expected ReportPanic to re-panic")
+			}
+
+			item := transport.data()[0]
+			if item["level"] != rollbar.CRIT {
+				t.Errorf("expected level %s, got %v", rollbar.CRIT, item["level"])
+			}
+
+			chain := item["body"].(map[string]interface{})["trace_chain"].([]map[string]interface{})
+			if len(chain) != c.chain {
+				t.Errorf("expected %d traces, got %d", c.chain, len(chain))
+			}
+
+			exception := chain[0]["exception"].(map[string]interface{})
+			if exception["class"] != c.class || exception["message"] != c.message {
+				t.Errorf("expected %s %q, got %v", c.class, c.message, exception)
+			}
+
+			frames := chain[0]["frames"].(rollbar.Stack)
+			if len(frames) == 0 || frames[0].Method != c.method {
+				t.Errorf("expected stack trace to start at %s, got %v", c.method, frames)
+			}
+		})
+	}
+}
+
+func TestHookReportPanicWithoutPanic(t *testing.T) {
+	h, transport := newTestHook()
+
+	if p := recoverReportPanic(h, func() {}); p != nil {
+		t.Fatalf("unexpected panic %v", p)
+	}
+	if len(transport.data()) != 0 {
+		t.Error("expected nothing to be reported")
+	}
+}
+
+func TestHookGoContext(t *testing.T) {
+	type key struct{}
+	panicked := make(chan interface{}, 1)
+	h, transport := newTestHook(
+		WithRecoverPanics(),
+		WithPanicFunc(func(ctx context.Context, v interface{}) {
+			if ctx.Value(key{}) != "value" {
+				t.Error("expected the panic func to get the context")
+			}
+			panicked <- v
+		}),
+		WithContextExtractor(func(ctx context.Context) ContextData {
+			id, _ := ctx.Value(key{}).(string)
+			return ContextData{RequestID: id}
+		}),
+	)
+
+	ctx := context.WithValue(context.Background(), key{}, "value")
+	h.GoContext(ctx, func(context.Context) {
+		panicWith("boom")
+	})
+
+	select {
+	case v := <-panicked:
+		if v != "boom" {
+			t.Errorf("expected boom, got %v", v)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the panic")
+	}
+
+	item := transport.data()[0]
+	if id := item["request"].(map[string]interface{})["request_id"]; id != "value" {
+		t.Errorf("expected the context to be reported, got %v", id)
+	}
+	if frames := firstTrace(item)["frames"].(rollbar.Stack); frames[0].Method != "rollrus.panicWith" {
+		t.Errorf("expected stack trace to start at the panic, got %v", frames)
+	}
+}
+
+func TestHookRecoverGoRepanics(t *testing.T) {
+	called := false
+	h, transport := newTestHook(WithPanicFunc(func(context.Context, interface{}) {
+		called = true
+	}))
+
+	p := func() (p interface{}) {
+		defer func() {
+			p = recover()
+		}()
+		defer h.recoverGo(context.Background())
+		panicWith("boom")
+		return nil
+	}()
+
+	if p != "boom" {
+		t.Errorf("expected a re-panic with boom, got %v", p)
+	}
+	if !called {
+		t.Error("expected the panic func to be called")
+	}
+	if len(transport.data()) != 1 {
+		t.Error("expected the panic to be reported")
+	}
+}
+
+func TestReportPanicAndClose(t *testing.T) {
+	before := runtime.NumGoroutine()
+
+	h, transport := newTestHook()
+	reportPanicAndClose(h, "boom", nil)
+
+	if n := len(transport.data()); n != 1 {
+		t.Fatalf("expected 1 report, got %d", n)
+	}
+
+	// the hook's transport stops delivering once closed.
+	deadline := time.Now().Add(time.Second)
+	for runtime.NumGoroutine() > before {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the hook's goroutines to stop, %d remain of %d", runtime.NumGoroutine(), before)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
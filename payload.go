@@ -0,0 +1,195 @@
+package rollrus
+
+import (
+	"fmt"
+	"hash/adler32"
+	"net"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+// payloadKey is the extras key used to pass a *payload from report to
+// transform.
+const payloadKey = "rollrus.payload"
+
+// payload holds the parts of a report that can't be expressed through the
+// rollbar.Client API. It is applied to the item by transform.
+type payload struct {
+	// stack replaces the stack trace captured by the client, for reports
+	// made after the entry was fired.
+	stack rollbar.Stack
+
+	// chain is the reported error followed by the errors it wraps, each of
+	// which is reported as a trace in the item's trace_chain.
+	chain []error
+
+	// person is passed to the client through the report's context.
+	person *rollbar.Person
+
+	// recovered is set for panics that were recovered, which aren't flushed
+	// as the program carries on.
+	recovered bool
+
+	// req is reported in the item's request section by the client.
+	req *http.Request
+
+	// request and custom are merged into the item's request and custom
+	// data, without replacing what's there.
+	request map[string]interface{}
+	custom  map[string]interface{}
+
+	// fingerprint, if set, decides which item the report is grouped into.
+	fingerprint string
+
+	// title, if set, replaces the item's title.
+	title string
+
+	// level is the Rollbar level the item is reported at. It is decided when
+	// the entry is fired, as its fields aren't kept once it is held back.
+	level string
+
+	// scrubbers redact sensitive data from the item.
+	scrubbers scrubbers
+
+	// maxSize is the size in bytes the item is truncated to, if positive.
+	maxSize int
+}
+
+// transform applies the payload passed in an item's custom data to the item.
+// It is installed as the Client's transform by NewHook.
+func transform(data map[string]interface{}) {
+	custom, _ := data["custom"].(map[string]interface{})
+	p, ok := custom[payloadKey].(*payload)
+	if !ok {
+		return
+	}
+	delete(custom, payloadKey)
+
+	merge(custom, p.custom)
+
+	if p.title != "" {
+		data["title"] = p.title
+	}
+
+	if p.fingerprint != "" {
+		data["fingerprint"] = p.fingerprint
+	}
+
+	if p.req != nil {
+		if request, ok := data["request"].(map[string]interface{}); ok {
+			request["user_ip"] = userIP(request["user_ip"])
+		}
+	}
+
+	if len(p.request) > 0 {
+		request, _ := data["request"].(map[string]interface{})
+		if request == nil {
+			request = make(map[string]interface{}, len(p.request))
+			data["request"] = request
+		}
+		merge(request, p.request)
+	}
+
+	if p.stack != nil {
+		if trace := firstTrace(data); trace != nil {
+			trace["frames"] = p.stack
+		}
+	}
+
+	if len(p.chain) > 0 {
+		if trace := firstTrace(data); trace != nil {
+			body := data["body"].(map[string]interface{})
+			body["trace_chain"] = traceChain(p.chain, trace["frames"])
+		}
+	}
+
+	p.scrubbers.scrubItem(data)
+	truncate(data, p.maxSize)
+}
+
+// userIP strips the port the client leaves in a request's remote address.
+func userIP(addr interface{}) interface{} {
+	s, ok := addr.(string)
+	if !ok {
+		return addr
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return host
+	}
+	return s
+}
+
+// merge copies the entries of src missing from dst to dst.
+func merge(dst, src map[string]interface{}) {
+	for k, v := range src {
+		if _, exists := dst[k]; !exists {
+			dst[k] = v
+		}
+	}
+}
+
+// traceChain builds a trace for every error in chain, outermost first. Each
+// trace gets the stack trace its error captured when it was created, if any.
+// Otherwise, the outermost trace gets frames, the stack trace captured by the
+// client. Errors that only add a stack trace to the error they wrap, such as
+// those returned by pkg/errors' Wrap and WithStack, aren't given a trace of
+// their own. Their stack trace is carried over to the error they wrap instead.
+func traceChain(chain []error, frames interface{}) []map[string]interface{} {
+	traces := make([]map[string]interface{}, 0, len(chain))
+	carried := frames
+	for i, err := range chain {
+		stack := carried
+		if s, ok := errorStack(err); ok {
+			stack = s
+		}
+
+		if i+1 < len(chain) && err.Error() == chain[i+1].Error() {
+			carried = stack
+			continue
+		}
+		if stack == nil {
+			stack = make(rollbar.Stack, 0)
+		}
+		carried = nil
+
+		traces = append(traces, map[string]interface{}{
+			"frames": stack,
+			"exception": map[string]interface{}{
+				"class":   errorClass(err),
+				"message": err.Error(),
+			},
+		})
+	}
+	return traces
+}
+
+// errorClass names the type of err the same way rollbar-go does. Panic
+// values that aren't errors are named after their own type.
+func errorClass(err error) string {
+	if p, ok := err.(*panicError); ok {
+		return fmt.Sprintf("panic(%T)", p.value)
+	}
+
+	class := reflect.TypeOf(err).String()
+	switch class {
+	case "":
+		return "panic"
+	case "*errors.errorString":
+		return fmt.Sprintf("{%x}", adler32.Checksum([]byte(err.Error())))
+	default:
+		return strings.TrimPrefix(class, "*")
+	}
+}
+
+// firstTrace returns the outermost trace of an error item.
+func firstTrace(data map[string]interface{}) map[string]interface{} {
+	body, _ := data["body"].(map[string]interface{})
+	chain, _ := body["trace_chain"].([]map[string]interface{})
+	if len(chain) == 0 {
+		return nil
+	}
+	return chain[0]
+}
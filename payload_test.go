@@ -0,0 +1,97 @@
+package rollrus
+
+import (
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestTraceChain(t *testing.T) {
+	h, transport := newTestHook()
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	entry.Data["err"] = fmt.Errorf("outer: %w", errors.WithMessage(io.EOF, "inner"))
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	item := transport.data()[0]
+	chain := item["body"].(map[string]interface{})["trace_chain"].([]map[string]interface{})
+
+	expected := []struct{ class, message string }{
+		{"fmt.wrapError", "outer: inner: EOF"},
+		{"errors.withMessage", "inner: EOF"},
+		{errorClass(io.EOF), "EOF"},
+	}
+	if len(chain) != len(expected) {
+		t.Fatalf("expected %d traces, got %d", len(expected), len(chain))
+	}
+
+	for i, e := range expected {
+		exception := chain[i]["exception"].(map[string]interface{})
+		if exception["class"] != e.class || exception["message"] != e.message {
+			t.Errorf("trace %d: expected %s %q, got %v", i, e.class, e.message, exception)
+		}
+	}
+
+	if frames := chain[0]["frames"].(rollbar.Stack); len(frames) == 0 {
+		t.Error("expected the outermost trace to have a stack trace")
+	}
+	if _, ok := item["custom"].(map[string]interface{})[payloadKey]; ok {
+		t.Error("expected payload to be removed from the item")
+	}
+}
+
+func TestTraceChainWrapped(t *testing.T) {
+	h, transport := newTestHook()
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	entry.Data["err"] = errors.Wrap(io.EOF, "reading")
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	item := transport.data()[0]
+	chain := item["body"].(map[string]interface{})["trace_chain"].([]map[string]interface{})
+
+	// the stack added by Wrap doesn't get a trace of its own.
+	expected := []struct{ class, message string }{
+		{"errors.withMessage", "reading: EOF"},
+		{errorClass(io.EOF), "EOF"},
+	}
+	if len(chain) != len(expected) {
+		t.Fatalf("expected %d traces, got %d", len(expected), len(chain))
+	}
+
+	for i, e := range expected {
+		exception := chain[i]["exception"].(map[string]interface{})
+		if exception["class"] != e.class || exception["message"] != e.message {
+			t.Errorf("trace %d: expected %s %q, got %v", i, e.class, e.message, exception)
+		}
+	}
+
+	frames := chain[0]["frames"].(rollbar.Stack)
+	if len(frames) == 0 || frames[0].Method != "rollrus.TestTraceChainWrapped" {
+		t.Errorf("expected the stack captured by Wrap, got %v", frames)
+	}
+	if frames := chain[1]["frames"].(rollbar.Stack); len(frames) != 0 {
+		t.Errorf("expected no stack for the wrapped error, got %v", frames)
+	}
+}
+
+func TestErrorClass(t *testing.T) {
+	if class := errorClass(&PGError{}); class != "rollrus.PGError" {
+		t.Errorf("expected pointers to be dereferenced, got %s", class)
+	}
+	if class := errorClass(fmt.Errorf("foo")); class[0] != '{' {
+		t.Errorf("expected a checksum for errors.New errors, got %s", class)
+	}
+}
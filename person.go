@@ -0,0 +1,53 @@
+package rollrus
+
+import (
+	"fmt"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+// personFields names the fields reported as the person affected by a report.
+type personFields struct {
+	id, username, email string
+}
+
+// extractPerson reports the person identified by the entry's fields instead
+// of the person found in its context, removing the fields from m. Entries
+// without an id field don't identify a person, so their fields are left as
+// they are.
+func (r *Hook) extractPerson(entry *logrus.Entry, m map[string]interface{}, p *payload) {
+	if r.personFields == nil {
+		return
+	}
+
+	f := r.personFields
+	id := personField(entry, f.id)
+	if id == "" {
+		return
+	}
+
+	p.person = &rollbar.Person{
+		Id:       id,
+		Username: personField(entry, f.username),
+		Email:    personField(entry, f.email),
+	}
+	for _, name := range []string{f.id, f.username, f.email} {
+		if name != "" {
+			delete(m, name)
+		}
+	}
+}
+
+// personField returns the value of the named field as a string.
+func personField(entry *logrus.Entry, name string) string {
+	if name == "" {
+		return ""
+	}
+
+	v, ok := entry.Data[name]
+	if !ok || v == nil {
+		return ""
+	}
+	return fmt.Sprint(v)
+}
@@ -0,0 +1,57 @@
+package rollrus
+
+import (
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithPersonFields(t *testing.T) {
+	h, transport := newTestHook(WithPersonFields("user_id", "", "user_email"))
+
+	entry := logrus.NewEntry(nil).WithFields(logrus.Fields{
+		"user_id":    1234,
+		"user_email": "gopher@example.com",
+		"other":      "field",
+	})
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	item := transport.data()[0]
+	person := item["person"].(map[string]string)
+	if person["id"] != "1234" || person["username"] != "" || person["email"] != "gopher@example.com" {
+		t.Errorf("unexpected person %v", person)
+	}
+
+	custom := item["custom"].(map[string]interface{})
+	for _, name := range []string{"user_id", "user_email"} {
+		if _, ok := custom[name]; ok {
+			t.Errorf("expected %s to be removed from custom data", name)
+		}
+	}
+	if custom["other"] != "field" {
+		t.Errorf("expected other fields to be kept, got %v", custom["other"])
+	}
+}
+
+func TestWithPersonFieldsWithoutID(t *testing.T) {
+	h, transport := newTestHook(WithPersonFields("user_id", "", "user_email"))
+
+	entry := logrus.NewEntry(nil).WithField("user_email", "gopher@example.com")
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	item := transport.data()[0]
+	if _, ok := item["person"]; ok {
+		t.Error("expected no person without an id")
+	}
+	if custom := item["custom"].(map[string]interface{}); custom["user_email"] != "gopher@example.com" {
+		t.Errorf("expected user_email to be kept, got %v", custom["user_email"])
+	}
+}
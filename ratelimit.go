@@ -0,0 +1,189 @@
+package rollrus
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+// defaultSummaryInterval is how long after a report is first suppressed the
+// suppressed reports are summarized when RateLimit.SummaryInterval isn't set.
+const defaultSummaryInterval = time.Minute
+
+// RateLimit configures how many reports a Hook makes before suppressing them.
+type RateLimit struct {
+	// Rate is the number of reports per second allowed across all errors,
+	// with bursts of up to Burst reports. Zero means unlimited. Burst
+	// defaults to Rate rounded up, and at least 1.
+	Rate  float64
+	Burst int
+
+	// PerFingerprint is the number of reports allowed for the same error
+	// within a sliding Window. Zero means unlimited. An error's fingerprint
+	// is made up of its cause and the location it was logged from.
+	PerFingerprint int
+	Window         time.Duration
+
+	// SummaryInterval is how long after a report is first suppressed a
+	// report summarizing the suppressed reports is made. It defaults to one
+	// minute.
+	SummaryInterval time.Duration
+}
+
+// rateLimiter applies a RateLimit, counting the reports it suppresses.
+type rateLimiter struct {
+	RateLimit
+	now    func() time.Time
+	report func(suppressed map[string]int)
+
+	mu         sync.Mutex
+	tokens     float64
+	refilled   time.Time
+	seen       map[string][]time.Time
+	swept      time.Time
+	suppressed map[string]int
+	timer      *time.Timer
+}
+
+func newRateLimiter(l RateLimit, report func(map[string]int)) *rateLimiter {
+	if l.SummaryInterval <= 0 {
+		l.SummaryInterval = defaultSummaryInterval
+	}
+	if l.Burst <= 0 {
+		l.Burst = int(math.Max(1, math.Ceil(l.Rate)))
+	}
+
+	now := time.Now()
+	return &rateLimiter{
+		RateLimit:  l,
+		now:        time.Now,
+		report:     report,
+		tokens:     float64(l.Burst),
+		refilled:   now,
+		seen:       make(map[string][]time.Time),
+		swept:      now,
+		suppressed: make(map[string]int),
+	}
+}
+
+// allow reports whether a report with the given fingerprint may be made,
+// counting it as suppressed if not.
+func (l *rateLimiter) allow(fingerprint string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if elapsed := now.Sub(l.refilled); l.Rate > 0 && elapsed > 0 {
+		l.tokens += elapsed.Seconds() * l.Rate
+		if burst := float64(l.Burst); l.tokens > burst {
+			l.tokens = burst
+		}
+		l.refilled = now
+	}
+
+	perFingerprint := l.PerFingerprint > 0 && l.Window > 0
+	var seen []time.Time
+	if perFingerprint {
+		if now.Sub(l.swept) > l.Window {
+			l.sweep(now)
+		}
+
+		seen = l.recent(l.seen[fingerprint], now)
+		l.seen[fingerprint] = seen
+		if len(seen) >= l.PerFingerprint {
+			l.suppress(fingerprint)
+			return false
+		}
+	}
+
+	if l.Rate > 0 {
+		if l.tokens < 1 {
+			l.suppress(fingerprint)
+			return false
+		}
+		l.tokens--
+	}
+
+	if perFingerprint {
+		l.seen[fingerprint] = append(seen, now)
+	}
+	return true
+}
+
+// recent returns the times within the window.
+func (l *rateLimiter) recent(times []time.Time, now time.Time) []time.Time {
+	i := 0
+	for i < len(times) && now.Sub(times[i]) >= l.Window {
+		i++
+	}
+	return times[i:]
+}
+
+// sweep forgets fingerprints that weren't seen within the window. The caller
+// must hold l.mu.
+func (l *rateLimiter) sweep(now time.Time) {
+	for fingerprint, times := range l.seen {
+		if len(l.recent(times, now)) == 0 {
+			delete(l.seen, fingerprint)
+		}
+	}
+	l.swept = now
+}
+
+// suppress counts a suppressed report, scheduling a summary if it is the
+// first since the last one. The caller must hold l.mu.
+func (l *rateLimiter) suppress(fingerprint string) {
+	l.suppressed[fingerprint]++
+	if l.timer == nil {
+		l.timer = time.AfterFunc(l.SummaryInterval, l.flush)
+	}
+}
+
+// flush reports the number of reports suppressed per fingerprint since the
+// last summary, if any.
+func (l *rateLimiter) flush() {
+	l.mu.Lock()
+	suppressed := l.suppressed
+	l.suppressed = make(map[string]int)
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	l.mu.Unlock()
+
+	if len(suppressed) > 0 {
+		l.report(suppressed)
+	}
+}
+
+// errorFingerprint identifies reports of the same cause logged from the same
+// place.
+func errorFingerprint(cause error) string {
+	frame := callerFrame()
+	if cause == nil {
+		return fmt.Sprintf("<nil> at %s:%d", frame.File, frame.Line)
+	}
+	return fmt.Sprintf("%T: %s at %s:%d", cause, cause.Error(), frame.File, frame.Line)
+}
+
+// reportSuppressed makes a report summarizing the reports suppressed by the
+// rate limit.
+func (r *Hook) reportSuppressed(suppressed map[string]int) {
+	// fingerprints include error messages, which may be sensitive.
+	ss := r.scrubbers()
+	byFingerprint := make(map[string]int, len(suppressed))
+	var total int
+	for fingerprint, n := range suppressed {
+		byFingerprint[ss.scrubString(fingerprint)] += n
+		total += n
+	}
+
+	msg := fmt.Sprintf("rollrus suppressed %d reports exceeding the rate limit", total)
+	r.Client.MessageWithExtras(rollbar.WARN, msg, map[string]interface{}{
+		"suppressed":                total,
+		"suppressed_by_fingerprint": byFingerprint,
+	})
+}
@@ -0,0 +1,173 @@
+package rollrus
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestRateLimiterGlobalRate(t *testing.T) {
+	var summaries []map[string]int
+	l := newRateLimiter(RateLimit{Rate: 1, Burst: 2}, func(s map[string]int) {
+		summaries = append(summaries, s)
+	})
+	defer l.flush()
+	now := l.refilled
+	l.now = func() time.Time { return now }
+
+	for i, want := range []bool{true, true, false} {
+		if got := l.allow("a"); got != want {
+			t.Fatalf("allow #%d = %t, want %t", i, got, want)
+		}
+	}
+
+	now = now.Add(time.Second)
+	if !l.allow("b") {
+		t.Fatal("expected a token to be refilled after a second")
+	}
+	if l.allow("b") {
+		t.Fatal("expected only a single token to be refilled")
+	}
+
+	l.flush()
+	if len(summaries) != 1 || summaries[0]["a"] != 1 || summaries[0]["b"] != 1 {
+		t.Fatalf("expected 1 suppressed report each, got %v", summaries)
+	}
+	l.flush()
+	if len(summaries) != 1 {
+		t.Fatalf("expected summary to be reset, got %v", summaries)
+	}
+}
+
+func TestRateLimiterDefaultBurst(t *testing.T) {
+	for _, tc := range []struct {
+		rate  float64
+		burst int
+	}{
+		{rate: 100, burst: 100},
+		{rate: 2.5, burst: 3},
+		{rate: 0.1, burst: 1},
+	} {
+		l := newRateLimiter(RateLimit{Rate: tc.rate}, func(map[string]int) {})
+		now := l.refilled
+		l.now = func() time.Time { return now }
+
+		for i := 0; i < tc.burst; i++ {
+			if !l.allow("a") {
+				t.Fatalf("rate %v: report #%d suppressed, want a burst of %d", tc.rate, i, tc.burst)
+			}
+		}
+		if l.allow("a") {
+			t.Fatalf("rate %v: expected reports beyond a burst of %d to be suppressed", tc.rate, tc.burst)
+		}
+
+		now = now.Add(time.Minute)
+		if !l.allow("a") {
+			t.Fatalf("rate %v: expected tokens to be refilled", tc.rate)
+		}
+	}
+}
+
+func TestRateLimiterPerFingerprint(t *testing.T) {
+	l := newRateLimiter(RateLimit{PerFingerprint: 2, Window: time.Minute}, func(map[string]int) {})
+	defer l.flush()
+	now := l.refilled
+	l.now = func() time.Time { return now }
+
+	l.allow("a")
+	now = now.Add(30 * time.Second)
+	l.allow("a")
+
+	if l.allow("a") {
+		t.Fatal("expected fingerprint to be limited")
+	}
+	if !l.allow("b") {
+		t.Fatal("expected other fingerprints to be allowed")
+	}
+
+	// the first report slides out of the window.
+	now = now.Add(31 * time.Second)
+	if !l.allow("a") {
+		t.Fatal("expected fingerprint to be allowed once the window slides")
+	}
+	if l.allow("a") {
+		t.Fatal("expected fingerprint to be limited again")
+	}
+}
+
+func TestWithRateLimit(t *testing.T) {
+	h, transport := newTestHook(WithRateLimit(RateLimit{PerFingerprint: 1, Window: time.Hour}))
+
+	fire := func(err error, level logrus.Level) {
+		t.Helper()
+		atomic.StoreInt32(&h.reported, 0)
+
+		entry := logrus.NewEntry(nil)
+		entry.Message = "This is a test"
+		entry.Level = level
+		entry.Data["err"] = err
+		if err := h.Fire(entry); err != nil {
+			t.Fatal("unexpected error ", err)
+		}
+	}
+
+	hot := errors.New("hot loop")
+	for i := 0; i < 3; i++ {
+		fire(hot, logrus.ErrorLevel)
+		if reported(h) != (i == 0) {
+			t.Fatalf("report #%d: reported = %t", i, reported(h))
+		}
+	}
+
+	fire(errors.New("other"), logrus.ErrorLevel)
+	if !reported(h) {
+		t.Fatal("expected other errors to be reported")
+	}
+
+	fire(hot, logrus.FatalLevel)
+	if !reported(h) {
+		t.Fatal("expected fatal errors to be reported")
+	}
+
+	// the fatal error is followed by a summary of the suppressed reports.
+	items := transport.data()
+	summary := items[len(items)-1]["custom"].(map[string]interface{})
+	if summary["suppressed"] != 2 {
+		t.Fatalf("expected a summary of 2 suppressed reports, got %v", summary)
+	}
+}
+
+func TestRateLimitSummaryInterval(t *testing.T) {
+	h, transport := newTestHook(WithRateLimit(RateLimit{
+		PerFingerprint:  1,
+		Window:          time.Hour,
+		SummaryInterval: time.Millisecond,
+	}))
+
+	// nothing is logged after the hot loop stops, so the summary is only
+	// made by the timer.
+	for i := 0; i < 3; i++ {
+		entry := logrus.NewEntry(nil)
+		entry.Message = "This is a test"
+		entry.Level = logrus.ErrorLevel
+		if err := h.Fire(entry); err != nil {
+			t.Fatal("unexpected error ", err)
+		}
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for len(transport.data()) < 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected a summary once the interval passed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	summary := transport.data()[1]["custom"].(map[string]interface{})
+	if summary["suppressed"] != 2 {
+		t.Fatalf("expected a summary of 2 suppressed reports, got %v", summary)
+	}
+}
@@ -0,0 +1,55 @@
+package rollrus
+
+import (
+	"net/http"
+	"regexp"
+	"sort"
+
+	"github.com/sirupsen/logrus"
+)
+
+// wellKnownRequestFields are the names of the fields to be checked for values
+// of type *http.Request, in priority order.
+var wellKnownRequestFields = []string{
+	"request", "req",
+}
+
+// defaultScrubHeaders matches the request headers that are never reported.
+var defaultScrubHeaders = regexp.MustCompile(`(?i)^(Authorization|Proxy-Authorization|Cookie|Set-Cookie)$`)
+
+// extractRequest reports the request logged in the entry's fields in the
+// item's request section, removing every field holding a request from m.
+func (r *Hook) extractRequest(entry *logrus.Entry, m map[string]interface{}, p *payload) {
+	// the field lists are shared by concurrent calls, so they aren't
+	// appended to each other.
+	p.req = requestField(entry.Data, r.requestFields)
+	if p.req == nil {
+		p.req = requestField(entry.Data, wellKnownRequestFields)
+	}
+
+	names := make([]string, 0, len(entry.Data))
+	for k, v := range entry.Data {
+		if _, ok := v.(*http.Request); ok {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	for _, k := range names {
+		if req := entry.Data[k].(*http.Request); p.req == nil && req != nil && req.URL != nil {
+			p.req = req
+		}
+		delete(m, k)
+	}
+}
+
+// requestField returns the request held by the first of the named fields
+// holding one, or nil.
+func requestField(data logrus.Fields, names []string) *http.Request {
+	for _, f := range names {
+		if req, ok := data[f].(*http.Request); ok && req != nil && req.URL != nil {
+			return req
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package rollrus
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestRequestFields(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://example.com/charge?id=1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("Cookie", "session=secret")
+	req.Header.Set("X-Request-Id", "abc")
+
+	for _, c := range []struct {
+		name string
+		opts []OptionFunc
+	}{
+		{"request", nil},
+		{"r", []OptionFunc{WithRequestField("r")}},
+		{"incoming", nil},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			h, transport := newTestHook(c.opts...)
+
+			log := logrus.New()
+			log.Out = ioutil.Discard
+			log.AddHook(h)
+			log.WithField(c.name, req).Error("This is a test")
+
+			item := transport.data()[0]
+			request, ok := item["request"].(map[string]interface{})
+			if !ok {
+				t.Fatal("expected a request section")
+			}
+			if request["url"] != "http://example.com/charge?id=1" || request["method"] != "POST" {
+				t.Errorf("unexpected request %v", request)
+			}
+			if request["query_string"] != "id=1" || request["user_ip"] != "192.0.2.1" {
+				t.Errorf("unexpected request %v", request)
+			}
+
+			headers := request["headers"].(map[string]interface{})
+			for _, name := range []string{"Authorization", "Cookie"} {
+				if headers[name] != rollbar.FILTERED {
+					t.Errorf("expected %s header to be scrubbed, got %v", name, headers[name])
+				}
+			}
+			if headers["X-Request-Id"] != "abc" {
+				t.Errorf("expected X-Request-Id header, got %v", headers["X-Request-Id"])
+			}
+
+			if _, ok := item["custom"].(map[string]interface{})[c.name]; ok {
+				t.Error("expected request field to be removed from custom data")
+			}
+
+			frames := firstTrace(item)["frames"].(rollbar.Stack)
+			if top := frames[0]; !strings.HasSuffix(top.Filename, "request_test.go") {
+				t.Errorf("expected stack trace to start in the test, got %v", top)
+			}
+		})
+	}
+}
+
+func TestRequestFieldsConcurrent(t *testing.T) {
+	req := httptest.NewRequest("GET", "http://example.com/", nil)
+
+	// enough fields that the list has spare capacity.
+	var opts []OptionFunc
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		opts = append(opts, WithRequestField(name))
+	}
+	h, _ := newTestHook(opts...)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			entry := logrus.NewEntry(nil).WithField("req", req)
+			p := &payload{}
+			h.extractRequest(entry, convertFields(entry.Data), p)
+			if p.req != req {
+				t.Error("expected the request to be extracted")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -1,12 +1,37 @@
 package rollrus
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"time"
 
 	"github.com/rollbar/rollbar-go"
 	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/rollrus/internal/transport"
+)
+
+var (
+	// ErrBufferFull is reported to delivery failure funcs when a report is
+	// dropped because too many reports are waiting to be sent.
+	ErrBufferFull = transport.ErrBufferFull
+	// ErrTransportClosed is reported to delivery failure funcs when a report
+	// is rejected because the hook has been closed.
+	ErrTransportClosed = transport.ErrClosed
 )
 
+// Stats is a snapshot of a Hook's report delivery counters.
+type Stats = transport.Stats
+
+// AbandonedError is returned by Hook.Flush and Hook.Close when their context
+// is done before all reports are delivered.
+type AbandonedError = transport.AbandonedError
+
+// defaultFlushTimeout bounds how long Fatal and Panic entries wait for
+// pending reports to be delivered.
+const defaultFlushTimeout = 10 * time.Second
+
 var defaultTriggerLevels = []logrus.Level{
 	logrus.ErrorLevel,
 	logrus.FatalLevel,
@@ -22,13 +47,7 @@ var wellKnownErrorFields = []string{
 // NewHook creates a hook that is intended for use with your own logrus.Logger
 // instance. Uses the default report levels defined in wellKnownErrorFields.
 func NewHook(token string, env string, opts ...OptionFunc) *Hook {
-	h := NewHookForLevels(token, env, defaultTriggerLevels)
-
-	for _, o := range opts {
-		o(h)
-	}
-
-	return h
+	return newHook(token, env, defaultTriggerLevels, opts...)
 }
 
 // SetupLogging for use on Heroku. If token is not an empty string a Rollbar
@@ -53,15 +72,28 @@ func setupLogging(token, env string, levels []logrus.Level) {
 }
 
 // ReportPanic attempts to report the panic to Rollbar using the provided
-// client and then re-panic. If it can't report the panic it will print an
-// error to stderr.
+// token and then re-panic. If it can't report the panic it will print an
+// error to stderr. The panic is reported the same way as by Hook.ReportPanic,
+// which reuses an existing hook instead.
 func ReportPanic(token, env string) {
 	if token != "" {
 		if p := recover(); p != nil {
 			defer panic(p)
-			r := rollbar.New(token, env, "", "", "")
-			r.ErrorWithLevel(rollbar.CRIT, fmt.Errorf("panic: %q", p))
-			r.Wait()
+			reportPanicAndClose(NewHook(token, env), p, panicStack())
 		}
 	}
 }
+
+// reportPanicAndClose reports the panic value v, which panicked with stack,
+// and closes h, waiting up to defaultFlushTimeout for the report to be
+// delivered.
+func reportPanicAndClose(h *Hook, v interface{}, stack rollbar.Stack) {
+	// closing h delivers the report, so it isn't flushed when it's made.
+	h.reportPanic(context.Background(), v, nil, &payload{stack: stack, recovered: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), defaultFlushTimeout)
+	defer cancel()
+	if err := h.Close(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "rollrus: %v\n", err)
+	}
+}
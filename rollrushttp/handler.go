@@ -0,0 +1,204 @@
+// Package rollrushttp reports the panics and server errors of net/http
+// handlers, and the failed requests of net/http clients, to Rollbar through a
+// rollrus.Hook.
+package rollrushttp
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/rollrus"
+)
+
+// entryKey is the context key of the request scoped entry.
+type entryKey struct{}
+
+// Option configures a Handler.
+type Option func(*handler)
+
+// WithServerErrors reports responses with a 5xx status code written by the
+// wrapped handler, along with the request.
+func WithServerErrors() Option {
+	return func(h *handler) {
+		h.serverErrors = true
+	}
+}
+
+// WithPanicResponse writes the response to requests whose handler panicked
+// with resp, instead of a plain 500 Internal Server Error. It isn't called if
+// the handler already wrote a response.
+func WithPanicResponse(resp http.Handler) Option {
+	return func(h *handler) {
+		h.panicResponse = resp
+	}
+}
+
+// WithLogger creates the request scoped entries from logger, instead of from
+// the standard logger.
+func WithLogger(logger *logrus.Logger) Option {
+	return func(h *handler) {
+		h.logger = logger
+	}
+}
+
+type handler struct {
+	hook          *rollrus.Hook
+	next          http.Handler
+	logger        *logrus.Logger
+	serverErrors  bool
+	panicResponse http.Handler
+}
+
+// Handler wraps next, recovering and reporting its panics to Rollbar through
+// hook along with the request. A 500 response is written once a panic is
+// reported. The request's context carries a request scoped entry, returned
+// by Entry.
+func Handler(hook *rollrus.Hook, next http.Handler, opts ...Option) http.Handler {
+	h := &handler{
+		hook:          hook,
+		next:          next,
+		logger:        logrus.StandardLogger(),
+		panicResponse: http.HandlerFunc(internalServerError),
+	}
+
+	for _, o := range opts {
+		o(h)
+	}
+
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	entry := h.logger.WithContext(ctx).WithFields(logrus.Fields{
+		"method": r.Method,
+		"path":   r.URL.Path,
+	})
+	r = r.WithContext(context.WithValue(ctx, entryKey{}, entry))
+
+	rw := &responseWriter{ResponseWriter: w}
+	w = rw.wrap()
+	defer func() {
+		if p := recover(); p != nil {
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+
+			h.hook.ReportRecovered(r.Context(), p, logrus.Fields{"request": r})
+			if !rw.wroteHeader {
+				h.panicResponse.ServeHTTP(w, r)
+			}
+			return
+		}
+
+		if h.serverErrors && rw.status >= 500 {
+			h.reportServerError(r, rw.status)
+		}
+	}()
+
+	h.next.ServeHTTP(w, r)
+}
+
+// reportServerError reports a 5xx response to r.
+func (h *handler) reportServerError(r *http.Request, status int) {
+	entry := logrus.NewEntry(h.logger).WithContext(r.Context()).WithFields(logrus.Fields{
+		"request": r,
+		"status":  status,
+	})
+	entry.Level = logrus.ErrorLevel
+	entry.Message = fmt.Sprintf("%s %s responded %d %s", r.Method, r.URL.Path, status, http.StatusText(status))
+	_ = h.hook.Fire(entry)
+}
+
+// Entry returns the request scoped entry carried by ctx, or an entry of the
+// standard logger if there isn't one.
+func Entry(ctx context.Context) *logrus.Entry {
+	if entry, ok := ctx.Value(entryKey{}).(*logrus.Entry); ok {
+		return entry
+	}
+	return logrus.NewEntry(logrus.StandardLogger()).WithContext(ctx)
+}
+
+func internalServerError(w http.ResponseWriter, r *http.Request) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
+
+// responseWriter records the status code written to a ResponseWriter.
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *responseWriter) flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.ResponseWriter.(http.Flusher).Flush()
+}
+
+func (w *responseWriter) hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, buf, err := w.ResponseWriter.(http.Hijacker).Hijack()
+	if err == nil {
+		// the connection is the handler's now, so it mustn't be answered.
+		w.wroteHeader = true
+	}
+	return conn, buf, err
+}
+
+// wrap returns w as a ResponseWriter implementing the same optional
+// interfaces as the ResponseWriter it wraps, so that handlers checking for
+// them aren't misled.
+func (w *responseWriter) wrap() http.ResponseWriter {
+	_, flusher := w.ResponseWriter.(http.Flusher)
+	_, hijacker := w.ResponseWriter.(http.Hijacker)
+	switch {
+	case flusher && hijacker:
+		return flushHijackWriter{w}
+	case flusher:
+		return flushWriter{w}
+	case hijacker:
+		return hijackWriter{w}
+	default:
+		return w
+	}
+}
+
+type flushWriter struct{ *responseWriter }
+
+func (w flushWriter) Flush() { w.flush() }
+
+type hijackWriter struct{ *responseWriter }
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+type flushHijackWriter struct{ *responseWriter }
+
+func (w flushHijackWriter) Flush() { w.flush() }
+
+func (w flushHijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijack() }
+
+// Unwrap returns the wrapped ResponseWriter, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,219 @@
+package rollrushttp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/rollrus"
+)
+
+type testTransport struct {
+	rollbar.Transport
+
+	mu    sync.Mutex
+	items []map[string]interface{}
+}
+
+func (t *testTransport) Send(body map[string]interface{}) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.items = append(t.items, body["data"].(map[string]interface{}))
+	return nil
+}
+
+func (t *testTransport) data() []map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([]map[string]interface{}(nil), t.items...)
+}
+
+func newTestHook(opts ...rollrus.OptionFunc) (*rollrus.Hook, *testTransport) {
+	h := rollrus.NewHook("", "testing", opts...)
+	t := &testTransport{Transport: h.Client.Transport}
+	h.Client.Transport = t
+	return h, t
+}
+
+func TestHandlerPanic(t *testing.T) {
+	hook, transport := newTestHook()
+	h := Handler(hook, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "http://example.com/charge", nil))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500, got %d", w.Code)
+	}
+
+	items := transport.data()
+	if len(items) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(items))
+	}
+	if items[0]["level"] != rollbar.CRIT || items[0]["title"] != "panic: boom" {
+		t.Errorf("unexpected report %v", items[0])
+	}
+	request := items[0]["request"].(map[string]interface{})
+	if request["url"] != "http://example.com/charge" || request["method"] != "GET" {
+		t.Errorf("unexpected request %v", request)
+	}
+}
+
+func TestHandlerPanicRateLimit(t *testing.T) {
+	hook, transport := newTestHook(rollrus.WithRateLimit(rollrus.RateLimit{
+		PerFingerprint: 1,
+		Window:         time.Hour,
+	}))
+	h := Handler(hook, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Errorf("expected status 500, got %d", w.Code)
+		}
+	}
+
+	if n := len(transport.data()); n != 1 {
+		t.Fatalf("expected recovered panics to be rate limited to 1 report, got %d", n)
+	}
+}
+
+func TestHandlerPanicResponse(t *testing.T) {
+	hook, _ := newTestHook()
+	h := Handler(hook, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}), WithPanicResponse(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("sorry"))
+	})))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+
+	if w.Code != http.StatusServiceUnavailable || w.Body.String() != "sorry" {
+		t.Errorf("expected the configured response, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestHandlerServerErrors(t *testing.T) {
+	for _, c := range []struct {
+		name    string
+		status  int
+		opts    []Option
+		reports int
+	}{
+		{"ok", http.StatusOK, []Option{WithServerErrors()}, 0},
+		{"server error", http.StatusBadGateway, []Option{WithServerErrors()}, 1},
+		{"disabled", http.StatusBadGateway, nil, 0},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			hook, transport := newTestHook()
+			h := Handler(hook, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(c.status)
+			}), c.opts...)
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest("POST", "/charge", nil))
+
+			items := transport.data()
+			if len(items) != c.reports {
+				t.Fatalf("expected %d reports, got %d", c.reports, len(items))
+			}
+			if c.reports == 0 {
+				return
+			}
+
+			if title := items[0]["title"]; title != "POST /charge responded 502 Bad Gateway" {
+				t.Errorf("unexpected title %q", title)
+			}
+			if _, ok := items[0]["request"]; !ok {
+				t.Error("expected the request to be reported")
+			}
+		})
+	}
+}
+
+func TestHandlerEntry(t *testing.T) {
+	hook, _ := newTestHook()
+	logger := logrus.New()
+	logger.Out = ioutil.Discard
+
+	var entry *logrus.Entry
+	h := Handler(hook, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		entry = Entry(r.Context())
+	}), WithLogger(logger))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/charge", nil))
+
+	if entry == nil || entry.Logger != logger {
+		t.Fatal("expected a request scoped entry of the logger")
+	}
+	if entry.Data["method"] != "GET" || entry.Data["path"] != "/charge" {
+		t.Errorf("unexpected fields %v", entry.Data)
+	}
+
+	if Entry(context.Background()).Logger != logrus.StandardLogger() {
+		t.Error("expected an entry of the standard logger without a request scoped entry")
+	}
+}
+
+func TestHandlerAbort(t *testing.T) {
+	hook, transport := newTestHook()
+	h := Handler(hook, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	}))
+
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("expected ErrAbortHandler to be re-panicked, got %v", p)
+		}
+		if len(transport.data()) != 0 {
+			t.Error("expected aborts not to be reported")
+		}
+	}()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestHandlerOptionalInterfaces(t *testing.T) {
+	hook, _ := newTestHook()
+
+	var flusher, hijacker bool
+	h := Handler(hook, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, flusher = w.(http.Flusher)
+		_, hijacker = w.(http.Hijacker)
+	}))
+
+	// a ResponseRecorder is an http.Flusher, but not an http.Hijacker.
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if !flusher || hijacker {
+		t.Errorf("expected only http.Flusher to be implemented, got flusher %t, hijacker %t", flusher, hijacker)
+	}
+
+	h.ServeHTTP(struct{ http.ResponseWriter }{httptest.NewRecorder()}, httptest.NewRequest("GET", "/", nil))
+	if flusher || hijacker {
+		t.Errorf("expected no optional interfaces to be implemented, got flusher %t, hijacker %t", flusher, hijacker)
+	}
+
+	srv := httptest.NewServer(h)
+	defer srv.Close()
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if !flusher || !hijacker {
+		t.Errorf("expected both optional interfaces to be implemented, got flusher %t, hijacker %t", flusher, hijacker)
+	}
+}
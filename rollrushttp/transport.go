@@ -0,0 +1,150 @@
+package rollrushttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/heroku/rollrus"
+)
+
+// TransportOption configures a RoundTripper.
+type TransportOption func(*roundTripper)
+
+// WithReportedStatusCodes reports responses with one of the given status
+// codes, instead of responses with a 5xx status code.
+func WithReportedStatusCodes(codes ...int) TransportOption {
+	return func(t *roundTripper) {
+		reported := make(map[int]bool, len(codes))
+		for _, code := range codes {
+			reported[code] = true
+		}
+		t.reported = func(status int) bool {
+			return reported[status]
+		}
+	}
+}
+
+// WithReportCanceled reports requests that fail because their context was
+// canceled, which otherwise aren't reported as the caller abandoned them.
+func WithReportCanceled() TransportOption {
+	return func(t *roundTripper) {
+		t.reportCanceled = true
+	}
+}
+
+type roundTripper struct {
+	hook           *rollrus.Hook
+	next           http.RoundTripper
+	reported       func(status int) bool
+	reportCanceled bool
+}
+
+// RoundTripper wraps next, reporting failed requests to Rollbar through hook:
+// those that fail with an error, including timeouts, and those answered with
+// a 5xx status code. Requests canceled by the caller aren't reported. Reports
+// carry the host, method, status, latency and URL, without its credentials
+// and query, of the request as fields. Like any other fields, they are
+// reported as strings unless hook uses rollrus.WithStructuredFields. Reports
+// are grouped into a Rollbar item per host and status. If next is nil,
+// http.DefaultTransport is used.
+func RoundTripper(hook *rollrus.Hook, next http.RoundTripper, opts ...TransportOption) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	t := &roundTripper{
+		hook: hook,
+		next: next,
+		reported: func(status int) bool {
+			return status >= 500
+		},
+	}
+
+	for _, o := range opts {
+		o(t)
+	}
+
+	return t
+}
+
+func (t *roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	latency := time.Since(start)
+
+	switch {
+	case err != nil:
+		if errors.Is(err, context.Canceled) && !t.reportCanceled {
+			break
+		}
+		t.report(req, 0, latency, err)
+	case t.reported(resp.StatusCode):
+		t.report(req, resp.StatusCode, latency, nil)
+	}
+
+	return resp, err
+}
+
+// report reports a failed request, which got a response with the given
+// status or failed with err.
+func (t *roundTripper) report(req *http.Request, status int, latency time.Duration, err error) {
+	u := sanitizeURL(req.URL)
+	fields := logrus.Fields{
+		"host":       req.URL.Host,
+		"method":     req.Method,
+		"url":        u,
+		"latency_ms": latency.Nanoseconds() / int64(time.Millisecond),
+	}
+
+	var msg, fingerprint string
+	if err != nil {
+		// url.Errors include the request's URL, which may be sensitive.
+		if uerr, ok := err.(*url.Error); ok {
+			err = &url.Error{Op: uerr.Op, URL: u, Err: uerr.Err}
+		}
+
+		outcome := "error"
+		if isTimeout(err) {
+			outcome = "timeout"
+			fields["timeout"] = true
+		}
+		fields[logrus.ErrorKey] = err
+		fingerprint = fmt.Sprintf("rollrushttp: %s %s", req.URL.Host, outcome)
+		msg = fmt.Sprintf("%s %s failed: %v", req.Method, u, err)
+	} else {
+		fields["status"] = status
+		fingerprint = fmt.Sprintf("rollrushttp: %s %d", req.URL.Host, status)
+		msg = fmt.Sprintf("%s %s responded %d %s", req.Method, u, status, http.StatusText(status))
+	}
+
+	entry := logrus.NewEntry(logrus.StandardLogger()).WithContext(req.Context()).WithFields(fields)
+	entry.Level = logrus.ErrorLevel
+	entry.Message = msg
+	_ = t.hook.FireWithFingerprint(entry, fingerprint)
+}
+
+// sanitizeURL returns u without its user info, query and fragment.
+func sanitizeURL(u *url.URL) string {
+	s := *u
+	s.User = nil
+	s.RawQuery = ""
+	s.ForceQuery = false
+	s.Fragment = ""
+	return s.String()
+}
+
+// isTimeout reports whether err is due to a timeout.
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	var nerr net.Error
+	return errors.As(err, &nerr) && nerr.Timeout()
+}
@@ -0,0 +1,176 @@
+package rollrushttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/heroku/rollrus"
+)
+
+func TestRoundTripperStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ok" {
+			return
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	hook, transport := newTestHook()
+	client := &http.Client{Transport: RoundTripper(hook, nil)}
+
+	for _, path := range []string{"/ok", "/users/1?token=secret", "/users/2"} {
+		resp, err := client.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	items := transport.data()
+	if len(items) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(items))
+	}
+	if items[0]["fingerprint"] != items[1]["fingerprint"] {
+		t.Errorf("expected reports to be grouped, got %v and %v", items[0]["fingerprint"], items[1]["fingerprint"])
+	}
+
+	custom := items[0]["custom"].(map[string]interface{})
+	host := strings.TrimPrefix(srv.URL, "http://")
+	if custom["host"] != host || custom["method"] != "GET" || custom["status"] != "503" {
+		t.Errorf("unexpected fields %v", custom)
+	}
+	if custom["url"] != srv.URL+"/users/1" {
+		t.Errorf("expected the query to be removed from the url, got %v", custom["url"])
+	}
+	if _, ok := custom["latency_ms"]; !ok {
+		t.Error("expected the latency to be reported")
+	}
+
+	expected := "GET " + srv.URL + "/users/1 responded 503 Service Unavailable"
+	if items[0]["title"] != expected {
+		t.Errorf("expected title %q, got %q", expected, items[0]["title"])
+	}
+}
+
+func TestRoundTripperReportedStatusCodes(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer srv.Close()
+
+	hook, transport := newTestHook()
+	client := &http.Client{Transport: RoundTripper(hook, nil, WithReportedStatusCodes(http.StatusTooManyRequests))}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if len(transport.data()) != 1 {
+		t.Error("expected the configured status code to be reported")
+	}
+}
+
+func TestRoundTripperErrors(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	hook, transport := newTestHook()
+	client := &http.Client{Transport: RoundTripper(hook, nil)}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequest("GET", srv.URL+"/slow?token=secret", nil)
+	if _, err := client.Do(req.WithContext(ctx)); err == nil {
+		t.Fatal("expected a timeout")
+	}
+
+	if _, err := client.Get("http://127.0.0.1:1/?token=secret"); err == nil {
+		t.Fatal("expected a connection error")
+	}
+
+	items := transport.data()
+	if len(items) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(items))
+	}
+
+	if custom := items[0]["custom"].(map[string]interface{}); custom["timeout"] != "true" {
+		t.Errorf("expected a timeout, got %v", custom)
+	}
+	if items[0]["fingerprint"] == items[1]["fingerprint"] {
+		t.Error("expected timeouts and errors to be grouped separately")
+	}
+	for _, item := range items {
+		if title := item["title"].(string); strings.Contains(title, "secret") {
+			t.Errorf("expected the query to be removed from the title, got %q", title)
+		}
+	}
+}
+
+func TestRoundTripperStructuredFields(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	hook, transport := newTestHook(rollrus.WithStructuredFields(0, 0))
+	client := &http.Client{Transport: RoundTripper(hook, nil)}
+
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	custom := transport.data()[0]["custom"].(map[string]interface{})
+	if status, ok := custom["status"].(int64); !ok || status != http.StatusServiceUnavailable {
+		t.Errorf("expected a numeric status, got %#v", custom["status"])
+	}
+	if _, ok := custom["latency_ms"].(int64); !ok {
+		t.Errorf("expected a numeric latency, got %#v", custom["latency_ms"])
+	}
+}
+
+func TestRoundTripperCanceled(t *testing.T) {
+	block := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-block
+	}))
+	defer srv.Close()
+	defer close(block)
+
+	for _, c := range []struct {
+		name     string
+		opts     []TransportOption
+		expected int
+	}{
+		{"default", nil, 0},
+		{"reported", []TransportOption{WithReportCanceled()}, 1},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			hook, transport := newTestHook()
+			client := &http.Client{Transport: RoundTripper(hook, nil, c.opts...)}
+
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			req, _ := http.NewRequest("GET", srv.URL, nil)
+			if _, err := client.Do(req.WithContext(ctx)); err == nil {
+				t.Fatal("expected the request to be canceled")
+			}
+
+			if n := len(transport.data()); n != c.expected {
+				t.Errorf("expected %d reports, got %d", c.expected, n)
+			}
+		})
+	}
+}
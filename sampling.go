@@ -0,0 +1,56 @@
+package rollrus
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+
+	"github.com/sirupsen/logrus"
+)
+
+// sampleRateField is the extras key holding the rate a report was sampled at.
+const sampleRateField = "sample_rate"
+
+// Sampling configures which proportion of entries are reported.
+type Sampling struct {
+	// Rates is the proportion of entries reported for each level, between 0
+	// and 1. Entries with levels that aren't listed are always reported.
+	Rates map[logrus.Level]float64
+
+	// KeyField optionally names a field, such as a request ID, whose value
+	// decides whether an entry is sampled. All entries with the same value
+	// and level are either reported or not. Entries without the field are
+	// sampled at random.
+	KeyField string
+}
+
+// rate returns the sample rate for the level.
+func (s Sampling) rate(level logrus.Level) float64 {
+	rate, ok := s.Rates[level]
+	if !ok || rate > 1 {
+		return 1
+	}
+	return rate
+}
+
+// sample decides whether the entry is reported, returning the rate it was
+// sampled at.
+func (s Sampling) sample(entry *logrus.Entry) (float64, bool) {
+	rate := s.rate(entry.Level)
+	if rate >= 1 {
+		return rate, true
+	}
+	if rate <= 0 {
+		return rate, false
+	}
+
+	if key, ok := entry.Data[s.KeyField]; ok && s.KeyField != "" {
+		h := fnv.New64a()
+		fmt.Fprint(h, key)
+		return rate, float64(h.Sum64())/math.MaxUint64 < rate
+	}
+
+	// sampling doesn't need a secure source of randomness.
+	return rate, rand.Float64() < rate // nolint:gosec
+}
@@ -0,0 +1,97 @@
+package rollrus
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestSamplingRates(t *testing.T) {
+	s := Sampling{Rates: map[logrus.Level]float64{
+		logrus.ErrorLevel: 0,
+		logrus.WarnLevel:  0.5,
+	}}
+
+	entry := logrus.NewEntry(nil)
+	entry.Level = logrus.FatalLevel
+	if rate, ok := s.sample(entry); !ok || rate != 1 {
+		t.Errorf("expected unlisted levels to always be reported, got %v %t", rate, ok)
+	}
+
+	entry.Level = logrus.ErrorLevel
+	if _, ok := s.sample(entry); ok {
+		t.Error("expected a zero rate to never be reported")
+	}
+
+	entry.Level = logrus.WarnLevel
+	var reported int
+	for i := 0; i < 1000; i++ {
+		if rate, ok := s.sample(entry); ok {
+			reported++
+			if rate != 0.5 {
+				t.Fatalf("expected a rate of 0.5, got %v", rate)
+			}
+		}
+	}
+	if reported < 400 || reported > 600 {
+		t.Errorf("expected about half of the entries to be reported, got %d", reported)
+	}
+}
+
+func TestSamplingKeyField(t *testing.T) {
+	s := Sampling{
+		Rates:    map[logrus.Level]float64{logrus.ErrorLevel: 0.5},
+		KeyField: "request_id",
+	}
+
+	var reported int
+	for i := 0; i < 100; i++ {
+		entry := logrus.NewEntry(nil)
+		entry.Level = logrus.ErrorLevel
+		entry.Data["request_id"] = fmt.Sprintf("req-%d", i)
+
+		_, first := s.sample(entry)
+		for j := 0; j < 10; j++ {
+			if _, ok := s.sample(entry); ok != first {
+				t.Fatalf("expected request %d to be sampled consistently", i)
+			}
+		}
+		if first {
+			reported++
+		}
+	}
+	if reported == 0 || reported == 100 {
+		t.Errorf("expected some requests to be sampled, got %d", reported)
+	}
+}
+
+func TestWithSampling(t *testing.T) {
+	h, transport := newTestHook(
+		WithMinLevel(logrus.WarnLevel),
+		WithSampling(Sampling{Rates: map[logrus.Level]float64{
+			logrus.ErrorLevel: 1,
+			logrus.WarnLevel:  0.999999,
+		}}),
+	)
+
+	for _, level := range []logrus.Level{logrus.ErrorLevel, logrus.WarnLevel} {
+		entry := logrus.NewEntry(nil)
+		entry.Message = "This is a test"
+		entry.Level = level
+		if err := h.Fire(entry); err != nil {
+			t.Fatal("unexpected error ", err)
+		}
+	}
+
+	items := transport.data()
+	if len(items) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(items))
+	}
+	if _, ok := items[0]["custom"].(map[string]interface{})[sampleRateField]; ok {
+		t.Error("expected unsampled reports to not carry a sample rate")
+	}
+	if rate := items[1]["custom"].(map[string]interface{})[sampleRateField]; rate != 0.999999 {
+		t.Errorf("expected sample rate to be reported, got %v", rate)
+	}
+}
@@ -0,0 +1,176 @@
+package rollrus
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+var (
+	// BearerTokenPattern matches bearer tokens, as sent in Authorization
+	// headers.
+	BearerTokenPattern = regexp.MustCompile(`(?i)\bbearer\s+[a-z0-9\-._~+/]+=*`)
+
+	// CreditCardPattern matches Visa, Mastercard, American Express and
+	// Discover card numbers, optionally grouped with spaces or dashes.
+	CreditCardPattern = regexp.MustCompile(`\b(?:(?:4\d{3}|5[1-5]\d{2}|2[2-7]\d{2}|6(?:011|5\d{2}))(?:[ -]?\d{4}){3}|3[47]\d{2}[ -]?\d{6}[ -]?\d{5})\b`)
+
+	// EmailPattern matches email addresses.
+	EmailPattern = regexp.MustCompile(`(?i)\b[a-z0-9._%+\-]+@[a-z0-9.\-]+\.[a-z]{2,}\b`)
+)
+
+// DefaultScrubber is applied to every report unless WithoutDefaultScrubber
+// is used. It redacts fields commonly holding secrets, along with bearer
+// tokens and credit card numbers found anywhere.
+var DefaultScrubber = Scrubber{
+	FieldPatterns: []*regexp.Regexp{
+		regexp.MustCompile(`(?i)passw(or)?d|secret|token|api[_-]?key|authorization|cookie|credential|private[_-]?key`),
+	},
+	ValuePatterns: []*regexp.Regexp{
+		BearerTokenPattern,
+		CreditCardPattern,
+	},
+}
+
+// Scrubber redacts sensitive data from reports, replacing it with
+// rollbar.FILTERED. It applies to the message and error messages, and to the
+// custom data and request sections of the report, including nested values.
+type Scrubber struct {
+	// Fields are the names of fields whose values are redacted, matched
+	// case insensitively.
+	Fields []string
+
+	// FieldPatterns match the names of further fields whose values are
+	// redacted.
+	FieldPatterns []*regexp.Regexp
+
+	// ValuePatterns match the sensitive parts of strings, which are redacted
+	// wherever they appear.
+	ValuePatterns []*regexp.Regexp
+}
+
+// ScrubField reports whether the value of the named field is redacted.
+func (s Scrubber) ScrubField(name string) bool {
+	for _, f := range s.Fields {
+		if strings.EqualFold(f, name) {
+			return true
+		}
+	}
+	for _, re := range s.FieldPatterns {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ScrubString returns str with every match of the value patterns redacted.
+func (s Scrubber) ScrubString(str string) string {
+	for _, re := range s.ValuePatterns {
+		str = re.ReplaceAllLiteralString(str, rollbar.FILTERED)
+	}
+	return str
+}
+
+// Scrub returns v with sensitive data redacted. Maps and slices are copied
+// rather than modified. Values of other types are returned as they are.
+func (s Scrubber) Scrub(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return s.ScrubString(t)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			if s.ScrubField(k) {
+				m[k] = rollbar.FILTERED
+			} else {
+				m[k] = s.Scrub(v)
+			}
+		}
+		return m
+	case map[string]string:
+		m := make(map[string]string, len(t))
+		for k, v := range t {
+			if s.ScrubField(k) {
+				m[k] = rollbar.FILTERED
+			} else {
+				m[k] = s.ScrubString(v)
+			}
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = s.Scrub(v)
+		}
+		return l
+	case []string:
+		l := make([]string, len(t))
+		for i, v := range t {
+			l[i] = s.ScrubString(v)
+		}
+		return l
+	}
+	return v
+}
+
+// scrubbers applies a pipeline of Scrubbers in order.
+type scrubbers []Scrubber
+
+// scrubString scrubs str with every Scrubber.
+func (ss scrubbers) scrubString(str string) string {
+	for _, s := range ss {
+		str = s.ScrubString(str)
+	}
+	return str
+}
+
+// scrub scrubs v with every Scrubber.
+func (ss scrubbers) scrub(v interface{}) interface{} {
+	for _, s := range ss {
+		v = s.Scrub(v)
+	}
+	return v
+}
+
+// scrubbers returns the hook's scrubbing pipeline.
+func (r *Hook) scrubbers() scrubbers {
+	if r.noDefaultScrubber {
+		return r.customScrubbers
+	}
+	return append(scrubbers{DefaultScrubber}, r.customScrubbers...)
+}
+
+// scrubItem redacts the item's title, messages, custom data and request.
+func (ss scrubbers) scrubItem(data map[string]interface{}) {
+	if len(ss) == 0 {
+		return
+	}
+
+	if title, ok := data["title"].(string); ok {
+		data["title"] = ss.scrubString(title)
+	}
+
+	for _, section := range []string{"custom", "request"} {
+		if v, ok := data[section]; ok {
+			data[section] = ss.scrub(v)
+		}
+	}
+
+	body, _ := data["body"].(map[string]interface{})
+	if message, ok := body["message"].(map[string]interface{}); ok {
+		if s, ok := message["body"].(string); ok {
+			message["body"] = ss.scrubString(s)
+		}
+	}
+
+	traces, _ := body["trace_chain"].([]map[string]interface{})
+	for _, trace := range traces {
+		if exception, ok := trace["exception"].(map[string]interface{}); ok {
+			if s, ok := exception["message"].(string); ok {
+				exception["message"] = ss.scrubString(s)
+			}
+		}
+	}
+}
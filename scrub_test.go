@@ -0,0 +1,122 @@
+package rollrus
+
+import (
+	"errors"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestScrubber(t *testing.T) {
+	s := Scrubber{
+		Fields:        []string{"ssn"},
+		FieldPatterns: []*regexp.Regexp{regexp.MustCompile(`(?i)^x-api-`)},
+		ValuePatterns: []*regexp.Regexp{EmailPattern},
+	}
+
+	in := map[string]interface{}{
+		"SSN":       "123-45-6789",
+		"X-Api-Key": "abc",
+		"user":      "gopher@example.com",
+		"nested": map[string]interface{}{
+			"ssn":  "123-45-6789",
+			"list": []interface{}{"contact gopher@example.com", 1},
+		},
+		"headers": map[string]string{"ssn": "1"},
+		"values":  []string{"gopher@example.com"},
+	}
+	expected := map[string]interface{}{
+		"SSN":       rollbar.FILTERED,
+		"X-Api-Key": rollbar.FILTERED,
+		"user":      rollbar.FILTERED,
+		"nested": map[string]interface{}{
+			"ssn":  rollbar.FILTERED,
+			"list": []interface{}{"contact " + rollbar.FILTERED, 1},
+		},
+		"headers": map[string]string{"ssn": rollbar.FILTERED},
+		"values":  []string{rollbar.FILTERED},
+	}
+
+	if got := s.Scrub(in); !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+	if in["user"] != "gopher@example.com" || in["nested"].(map[string]interface{})["ssn"] != "123-45-6789" {
+		t.Error("expected the input not to be modified")
+	}
+}
+
+func TestDefaultScrubber(t *testing.T) {
+	cases := []struct {
+		value, expected string
+	}{
+		{"Authorization: Bearer abc.def-ghi", "Authorization: " + rollbar.FILTERED},
+		{"card 4111 1111 1111 1111 declined", "card " + rollbar.FILTERED + " declined"},
+		{"card 378282246310005 declined", "card " + rollbar.FILTERED + " declined"},
+		{"request 1760000000000 took 12ms", "request 1760000000000 took 12ms"},
+		{"gopher@example.com", "gopher@example.com"},
+	}
+
+	for _, c := range cases {
+		if got := DefaultScrubber.ScrubString(c.value); got != c.expected {
+			t.Errorf("%q: expected %q, got %q", c.value, c.expected, got)
+		}
+	}
+
+	for _, name := range []string{"password", "db_passwd", "client_secret", "access_token", "API_KEY", "Cookie"} {
+		if !DefaultScrubber.ScrubField(name) {
+			t.Errorf("expected %s to be scrubbed", name)
+		}
+	}
+	if DefaultScrubber.ScrubField("user") {
+		t.Error("expected user not to be scrubbed")
+	}
+}
+
+func TestHookScrubbing(t *testing.T) {
+	h, transport := newTestHook(WithScrubber(Scrubber{ValuePatterns: []*regexp.Regexp{EmailPattern}}))
+
+	entry := logrus.NewEntry(nil).WithFields(logrus.Fields{
+		"api_key": "abc",
+		"err":     errors.New("invalid token Bearer abc for gopher@example.com"),
+	})
+	entry.Message = "login failed for gopher@example.com"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	item := transport.data()[0]
+	expected := "invalid token " + rollbar.FILTERED + " for " + rollbar.FILTERED
+	if item["title"] != expected {
+		t.Errorf("expected title %q, got %q", expected, item["title"])
+	}
+	if message := firstTrace(item)["exception"].(map[string]interface{})["message"]; message != expected {
+		t.Errorf("expected exception message %q, got %q", expected, message)
+	}
+
+	custom := item["custom"].(map[string]interface{})
+	if custom["api_key"] != rollbar.FILTERED {
+		t.Errorf("expected api_key to be scrubbed, got %v", custom["api_key"])
+	}
+	if custom["msg"] != "login failed for "+rollbar.FILTERED {
+		t.Errorf("expected msg to be scrubbed, got %v", custom["msg"])
+	}
+}
+
+func TestWithoutDefaultScrubber(t *testing.T) {
+	h, transport := newTestHook(WithoutDefaultScrubber())
+
+	entry := logrus.NewEntry(nil).WithField("api_key", "abc")
+	entry.Message = "This is a test"
+	entry.Level = logrus.InfoLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	if custom := transport.data()[0]["custom"].(map[string]interface{}); custom["api_key"] != "abc" {
+		t.Errorf("expected api_key not to be scrubbed, got %v", custom["api_key"])
+	}
+}
@@ -0,0 +1,135 @@
+package rollrus
+
+import (
+	"os"
+	"runtime"
+	"strings"
+
+	pkgerrors "github.com/pkg/errors"
+	"github.com/rollbar/rollbar-go"
+)
+
+// maxStackDepth bounds the number of frames captured for a stack trace.
+const maxStackDepth = 64
+
+// knownFilePathPatterns are the prefixes rollbar-go shortens file paths to.
+var knownFilePathPatterns = []string{
+	"github.com/",
+	"code.google.com/",
+	"bitbucket.org/",
+	"launchpad.net/",
+}
+
+// callerFrame returns the frame that logged the entry being fired, the first
+// frame outside of logrus and rollrus.
+func callerFrame() runtime.Frame {
+	frames := callerFrames()
+	if len(frames) == 0 {
+		return runtime.Frame{}
+	}
+	return frames[0]
+}
+
+// callerStack returns the stack trace of the code that logged the entry being
+// fired, in the form rollbar-go reports it.
+func callerStack() rollbar.Stack {
+	return buildStack(callerFrames())
+}
+
+// callerFrames returns the frames of the current stack, starting with the
+// first frame outside of logrus and rollrus.
+func callerFrames() []runtime.Frame {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(3, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var all []runtime.Frame
+	start := -1
+	for {
+		frame, more := frames.Next()
+		internal := strings.HasPrefix(frame.Function, "github.com/heroku/rollrus") && !strings.HasSuffix(frame.File, "_test.go")
+		if start < 0 && !internal && !strings.Contains(frame.File, "github.com/sirupsen/logrus") {
+			start = len(all)
+		}
+		all = append(all, frame)
+		if !more {
+			break
+		}
+	}
+
+	if start < 0 {
+		return nil
+	}
+	return all[start:]
+}
+
+// errorStack returns the stack trace captured where err was created, for
+// errors that record one: rollbar.CauseStackers, github.com/pkg/errors errors
+// and errors exposing the program counters returned by runtime.Callers.
+func errorStack(err error) (rollbar.Stack, bool) {
+	var pcs []uintptr
+	switch e := err.(type) {
+	case rollbar.CauseStacker:
+		stack := e.Stack()
+		return stack, len(stack) > 0
+	case interface{ StackTrace() pkgerrors.StackTrace }:
+		for _, f := range e.StackTrace() {
+			pcs = append(pcs, uintptr(f))
+		}
+	case interface{ Callers() []uintptr }:
+		pcs = e.Callers()
+	}
+
+	if len(pcs) == 0 {
+		return nil, false
+	}
+	return callersStack(pcs), true
+}
+
+// callersStack converts program counters, as returned by runtime.Callers, to
+// a rollbar.Stack.
+func callersStack(pcs []uintptr) rollbar.Stack {
+	var all []runtime.Frame
+	frames := runtime.CallersFrames(pcs)
+	for {
+		frame, more := frames.Next()
+		all = append(all, frame)
+		if !more {
+			break
+		}
+	}
+	return buildStack(all)
+}
+
+// buildStack converts frames to a rollbar.Stack.
+func buildStack(frames []runtime.Frame) rollbar.Stack {
+	stack := make(rollbar.Stack, 0, len(frames))
+	for _, frame := range frames {
+		stack = append(stack, rollbarFrame(frame))
+	}
+	return stack
+}
+
+// rollbarFrame converts frame the same way rollbar.BuildStack does.
+func rollbarFrame(frame runtime.Frame) rollbar.Frame {
+	file := frame.File
+	if idx := strings.Index(file, "/src/pkg/"); idx != -1 {
+		file = file[idx+5:]
+	} else {
+		for _, pattern := range knownFilePathPatterns {
+			if idx := strings.Index(file, pattern); idx != -1 {
+				file = file[idx:]
+				break
+			}
+		}
+	}
+
+	method := frame.Function
+	if method == "" {
+		method = "???"
+	} else if end := strings.LastIndex(method, string(os.PathSeparator)); end != -1 {
+		method = method[end+1:]
+	}
+
+	return rollbar.Frame{Filename: file, Method: method, Line: frame.Line}
+}
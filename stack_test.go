@@ -0,0 +1,76 @@
+package rollrus
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+func originError() error {
+	return errors.New("origin")
+}
+
+// callersError records program counters like some error packages do.
+type callersError struct {
+	pcs []uintptr
+}
+
+func newCallersError() error {
+	pcs := make([]uintptr, maxStackDepth)
+	n := runtime.Callers(1, pcs)
+	return callersError{pcs: pcs[:n]}
+}
+
+func (e callersError) Error() string      { return "callers" }
+func (e callersError) Callers() []uintptr { return e.pcs }
+
+func TestErrorStack(t *testing.T) {
+	cases := []struct {
+		err    error
+		method string
+	}{
+		{originError(), "rollrus.originError"},
+		{newCallersError(), "rollrus.newCallersError"},
+	}
+
+	for _, c := range cases {
+		stack, ok := errorStack(c.err)
+		if !ok {
+			t.Fatalf("expected %v to have a stack", c.err)
+		}
+		if stack[0].Method != c.method {
+			t.Errorf("expected stack to start in %s, got %s", c.method, stack[0].Method)
+		}
+	}
+
+	if _, ok := errorStack(errors.WithMessage(originError(), "no stack")); ok {
+		t.Error("expected errors without their own stack to have none")
+	}
+}
+
+func TestReportUsesErrorStack(t *testing.T) {
+	h, transport := newTestHook()
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	entry.Data["err"] = originError()
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	frames := firstTrace(transport.data()[0])["frames"].(rollbar.Stack)
+	if frames[0].Method != "rollrus.originError" {
+		t.Fatalf("expected the error's own stack to be reported, got %v", frames)
+	}
+}
+
+func TestCallerStack(t *testing.T) {
+	stack := callerStack()
+	if len(stack) == 0 || stack[0].Method != "rollrus.TestCallerStack" {
+		t.Fatalf("expected stack to start in the test, got %v", stack)
+	}
+}
@@ -0,0 +1,138 @@
+package rollrus
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// maxStdLogLine bounds how much of a message without a trailing newline is
+// buffered before it's reported anyway.
+const maxStdLogLine = 64 * 1024
+
+var (
+	// stdLogTimestamp matches the date and time written by a *log.Logger.
+	stdLogTimestamp = regexp.MustCompile(`^(?:\d{4}/\d{2}/\d{2} )?(?:\d{2}:\d{2}:\d{2}(?:\.\d+)? )?`)
+
+	// stdLogFile matches the file and line written by a *log.Logger.
+	stdLogFile = regexp.MustCompile(`^(\S+\.go:\d+): `)
+
+	// stdLogLevel matches level prefixes such as "ERROR:" or "[warn]". The
+	// brackets or colon are required, so sentences starting with a level
+	// name aren't mistaken for a prefix.
+	stdLogLevel = regexp.MustCompile(`(?i)^(?:\[(panic|fatal|error|err|warning|warn|info|debug)\]:?|(panic|fatal|error|err|warning|warn|info|debug):)\s*`)
+)
+
+// stdLogLevels are the levels of the prefixes matched by stdLogLevel.
+var stdLogLevels = map[string]logrus.Level{
+	"panic":   logrus.PanicLevel,
+	"fatal":   logrus.FatalLevel,
+	"error":   logrus.ErrorLevel,
+	"err":     logrus.ErrorLevel,
+	"warning": logrus.WarnLevel,
+	"warn":    logrus.WarnLevel,
+	"info":    logrus.InfoLevel,
+	"debug":   logrus.DebugLevel,
+}
+
+// stdLogWriter reports the messages written by a *log.Logger.
+type stdLogWriter struct {
+	hook  *Hook
+	level logrus.Level
+
+	mu  sync.Mutex
+	buf []byte
+}
+
+// Writer returns an io.Writer reporting the messages written to it by a
+// *log.Logger, such as with log.SetOutput, so that packages logging through
+// the log package reach Rollbar too. Messages are reported at level, unless
+// they start with a level prefix such as "ERROR:", "[warn]" or "panic:".
+// Only messages at the hook's levels are reported. Panic and fatal messages
+// are reported like Panic and Fatal entries, waiting for pending reports to
+// be delivered before the write returns. Every write ending with a
+// newline is reported as a single message, as a *log.Logger writes each
+// message at once.
+func (r *Hook) Writer(level logrus.Level) io.Writer {
+	return &stdLogWriter{hook: r, level: level}
+}
+
+// StdLogger returns a *log.Logger reporting its messages through the hook,
+// as described by Writer.
+func (r *Hook) StdLogger(level logrus.Level) *log.Logger {
+	return log.New(r.Writer(level), "", 0)
+}
+
+func (w *stdLogWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.buf = append(w.buf, p...)
+	var msg string
+	if bytes.HasSuffix(w.buf, []byte("\n")) || len(w.buf) >= maxStdLogLine {
+		msg = string(w.buf)
+		w.buf = w.buf[:0]
+	}
+	w.mu.Unlock()
+
+	if msg != "" {
+		w.report(msg)
+	}
+	return len(p), nil
+}
+
+// report parses and reports a message.
+func (w *stdLogWriter) report(msg string) {
+	fields := logrus.Fields{}
+	level := w.level
+
+	msg = strings.TrimRight(msg, "\n")
+	msg = stdLogTimestamp.ReplaceAllLiteralString(msg, "")
+	if m := stdLogFile.FindStringSubmatch(msg); m != nil {
+		fields["file"] = m[1]
+		msg = msg[len(m[0]):]
+	}
+	if m := stdLogLevel.FindStringSubmatch(msg); m != nil {
+		level = stdLogLevels[strings.ToLower(m[1]+m[2])]
+		msg = msg[len(m[0]):]
+	}
+
+	if msg == "" || !w.hook.handles(level) {
+		return
+	}
+
+	entry := &logrus.Entry{
+		Data:    fields,
+		Time:    time.Now(),
+		Level:   level,
+		Message: msg,
+	}
+	// panic and fatal messages are flushed like Panic and Fatal entries, as
+	// log.Panic and log.Fatal panic and exit once they are written.
+	_ = w.hook.fire(entry, &payload{stack: buildStack(stdLogCallerFrames())})
+}
+
+// stdLogCallerFrames returns the frames of the code that logged through the
+// log package.
+func stdLogCallerFrames() []runtime.Frame {
+	frames := callerFrames()
+	for len(frames) > 0 && strings.HasPrefix(frames[0].Function, "log.") {
+		frames = frames[1:]
+	}
+	return frames
+}
+
+// handles reports whether the hook reports entries at level.
+func (r *Hook) handles(level logrus.Level) bool {
+	for _, l := range r.Levels() {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
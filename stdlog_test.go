@@ -0,0 +1,109 @@
+package rollrus
+
+import (
+	"log"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestStdLogger(t *testing.T) {
+	h, transport := newTestHook(WithMinLevel(logrus.WarnLevel))
+	l := h.StdLogger(logrus.ErrorLevel)
+	l.SetFlags(log.LstdFlags | log.Lmicroseconds | log.Lshortfile)
+
+	l.Print("connection reset")
+	l.Print("WARN: retrying")
+	l.Print("[info] connected")
+	l.Printf("panic: %s", "boom")
+	l.Print("[ERROR]: disk full")
+
+	// sentences starting with a level name don't have a level prefix.
+	l.Print("Error reading config file")
+	l.Print("Info about the world")
+	l.Print("warning signs ignored")
+
+	expected := []struct {
+		level, title string
+	}{
+		{rollbar.ERR, "connection reset"},
+		{rollbar.WARN, "retrying"},
+		{rollbar.CRIT, "boom"},
+		{rollbar.ERR, "disk full"},
+		{rollbar.ERR, "Error reading config file"},
+		{rollbar.ERR, "Info about the world"},
+		{rollbar.ERR, "warning signs ignored"},
+	}
+
+	items := transport.data()
+	if len(items) != len(expected) {
+		t.Fatalf("expected %d reports, got %d", len(expected), len(items))
+	}
+
+	for i, e := range expected {
+		if items[i]["level"] != e.level || items[i]["title"] != e.title {
+			t.Errorf("report %d: expected %s %q, got %v %q", i, e.level, e.title, items[i]["level"], items[i]["title"])
+		}
+	}
+
+	if file := items[0]["custom"].(map[string]interface{})["file"]; file != "stdlog_test.go:18" {
+		t.Errorf("expected file stdlog_test.go:18, got %v", file)
+	}
+
+	frames := firstTrace(items[0])["frames"].(rollbar.Stack)
+	if len(frames) == 0 || !strings.HasSuffix(frames[0].Filename, "stdlog_test.go") {
+		t.Errorf("expected stack trace to start in the test, got %v", frames)
+	}
+}
+
+func TestWriterPartialWrites(t *testing.T) {
+	h, transport := newTestHook()
+	w := h.Writer(logrus.ErrorLevel)
+
+	_, _ = w.Write([]byte("connection "))
+	if len(transport.data()) != 0 {
+		t.Fatal("expected partial messages to be buffered")
+	}
+	_, _ = w.Write([]byte("reset\n"))
+
+	items := transport.data()
+	if len(items) != 1 || items[0]["title"] != "connection reset" {
+		t.Errorf("expected one report of the whole message, got %v", items)
+	}
+}
+
+// waitTransport counts the times it is waited on.
+type waitTransport struct {
+	*testTransport
+	waits int32
+}
+
+func (t *waitTransport) Wait() {
+	atomic.AddInt32(&t.waits, 1)
+	t.testTransport.Wait()
+}
+
+func TestStdLoggerFlush(t *testing.T) {
+	h, transport := newTestHook()
+	wt := &waitTransport{testTransport: transport}
+	h.Client.Transport = wt
+	l := h.StdLogger(logrus.ErrorLevel)
+
+	l.Print("connection reset")
+	if n := atomic.LoadInt32(&wt.waits); n != 0 {
+		t.Fatalf("expected error messages not to be flushed, got %d flushes", n)
+	}
+
+	l.Print("fatal: out of disk space")
+	if n := atomic.LoadInt32(&wt.waits); n != 1 {
+		t.Fatalf("expected fatal messages to be flushed, got %d flushes", n)
+	}
+
+	items := transport.data()
+	if len(items) != 2 || items[1]["level"] != rollbar.CRIT {
+		t.Errorf("expected a %s report, got %v", rollbar.CRIT, items)
+	}
+}
@@ -0,0 +1,47 @@
+package rollrus
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// TitleData is what a title template passed to WithTitleTemplate is executed
+// with.
+type TitleData struct {
+	// Message is the entry's message.
+	Message string
+	// Level is the entry's level, such as "error".
+	Level string
+	// Error is the message of the error extracted from the entry, which is
+	// the entry's message if it has no error field.
+	Error string
+	// ErrorType is the type of the innermost error in the error's chain,
+	// such as "*net.OpError".
+	ErrorType string
+	// Fields are the entry's fields.
+	Fields logrus.Fields
+}
+
+// renderTitle executes the hook's title template for the entry. It returns
+// an empty string if the template fails.
+func (r *Hook) renderTitle(entry *logrus.Entry, err error) string {
+	d := TitleData{
+		Message: entry.Message,
+		Level:   entry.Level.String(),
+		Fields:  entry.Data,
+	}
+	if err != nil {
+		d.Error = err.Error()
+		d.ErrorType = fmt.Sprintf("%T", errorCause(err))
+	}
+
+	var b strings.Builder
+	if err := r.titleTemplate.Execute(&b, d); err != nil {
+		fmt.Fprintf(os.Stderr, "rollrus: unable to render title: %v\n", err)
+		return ""
+	}
+	return b.String()
+}
@@ -0,0 +1,46 @@
+package rollrus
+
+import (
+	"fmt"
+	"testing"
+	"text/template"
+
+	"github.com/sirupsen/logrus"
+)
+
+func TestWithTitleTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("title").Parse(`[{{.Fields.service}}] {{.Message}}: {{.Fields.reason}} ({{.Level}}, {{.ErrorType}})`))
+	h, transport := newTestHook(WithTitleTemplate(tmpl))
+
+	entry := logrus.NewEntry(nil).WithFields(logrus.Fields{
+		"service": "billing",
+		"reason":  "card_declined",
+		"err":     fmt.Errorf("charging: %w", &PGError{}),
+	})
+	entry.Message = "charge failed"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	expected := "[billing] charge failed: card_declined (error, *rollrus.PGError)"
+	if title := transport.data()[0]["title"]; title != expected {
+		t.Errorf("expected title %q, got %q", expected, title)
+	}
+}
+
+func TestWithTitleTemplateFailure(t *testing.T) {
+	tmpl := template.Must(template.New("title").Option("missingkey=error").Parse(`{{.Fields.missing}}`))
+	h, transport := newTestHook(WithTitleTemplate(tmpl))
+
+	entry := logrus.NewEntry(nil)
+	entry.Message = "charge failed"
+	entry.Level = logrus.ErrorLevel
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	if title := transport.data()[0]["title"]; title != "charge failed" {
+		t.Errorf("expected the default title, got %q", title)
+	}
+}
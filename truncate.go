@@ -0,0 +1,366 @@
+package rollrus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/rollbar/rollbar-go"
+)
+
+const (
+	// defaultMaxPayloadSize keeps items within the size Rollbar accepts.
+	defaultMaxPayloadSize = 512 * 1024
+
+	// truncatedField is the custom data field recording what was truncated.
+	truncatedField = "truncated"
+
+	// keepFrames is the number of frames kept at each end of a trimmed
+	// stack trace.
+	keepFrames = 10
+)
+
+// stringLimits are the lengths long strings are shrunk to, in turn, until
+// the item fits.
+var stringLimits = []int{1024, 256}
+
+// keptFields are the custom data fields added by rollrus, which are only
+// dropped once all other fields have been.
+var keptFields = map[string]bool{
+	"msg":           true,
+	"time":          true,
+	"occurrences":   true,
+	"first_seen":    true,
+	"last_seen":     true,
+	sampleRateField: true,
+}
+
+// truncation records what was removed from an item to fit it within a size.
+type truncation struct {
+	OriginalSize  int      `json:"original_size"`
+	Strings       int      `json:"strings,omitempty"`
+	Frames        int      `json:"frames,omitempty"`
+	DroppedFields []string `json:"dropped_fields,omitempty"`
+}
+
+// truncate shrinks the item's data until it encodes to at most max bytes,
+// recording what was truncated in its custom data. Long strings are
+// shortened first, then stack traces are trimmed and finally custom data
+// fields are dropped, largest first, keeping those added by rollrus for last.
+// Custom data fields that can't be encoded are always dropped. The item is
+// only encoded to measure it if an estimate of its size exceeds max, as
+// encoding every item would be expensive.
+func truncate(data map[string]interface{}, max int) {
+	custom, _ := data["custom"].(map[string]interface{})
+
+	var t truncation
+	estimate := estimateSize(data)
+	if estimate < 0 {
+		t.DroppedFields = dropUnencodable(custom)
+		estimate = encodedSize(data)
+		t.OriginalSize = estimate
+	}
+
+	size := estimate
+	if max > 0 && estimate > max {
+		size = encodedSize(data)
+		t.OriginalSize = size
+	}
+	if len(t.DroppedFields) == 0 && (max <= 0 || size <= max) {
+		return
+	}
+
+	// every pass shortens the strings shortened by the previous one again, so
+	// the last count covers them all.
+	for _, limit := range stringLimits {
+		if max <= 0 || size <= max {
+			break
+		}
+		t.Strings = shortenItem(data, limit)
+		size = encodedSize(data)
+	}
+
+	if max > 0 && size > max {
+		t.Frames = trimFrames(data)
+		size = encodedSize(data)
+	}
+
+	// shortening strings copies the custom data.
+	custom, _ = data["custom"].(map[string]interface{})
+	if max > 0 && size > max {
+		t.DroppedFields = append(t.DroppedFields, dropFields(custom, size-max)...)
+	}
+
+	if custom == nil {
+		custom = make(map[string]interface{})
+		data["custom"] = custom
+	}
+	custom[truncatedField] = t
+}
+
+// encodedSize returns the size of v encoded as JSON, or -1 if it can't be
+// encoded.
+func encodedSize(v interface{}) int {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return -1
+	}
+	return len(b)
+}
+
+// estimateSize returns an upper bound of the size of v encoded as JSON, or -1
+// if it can't be encoded. The types making up items are measured without
+// encoding them.
+func estimateSize(v interface{}) int {
+	switch t := v.(type) {
+	case nil:
+		return len("null")
+	case bool:
+		return len("false")
+	case string:
+		return stringSize(t)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		return len("-9223372036854775808")
+	case float32:
+		return floatSize(float64(t))
+	case float64:
+		return floatSize(t)
+	case map[string]string:
+		n := len("{}")
+		for k, v := range t {
+			n += stringSize(k) + stringSize(v) + len(":,")
+		}
+		return n
+	case map[string]interface{}:
+		n := len("{}")
+		for k, v := range t {
+			size := estimateSize(v)
+			if size < 0 {
+				return -1
+			}
+			n += stringSize(k) + size + len(":,")
+		}
+		return n
+	case []string:
+		n := len("[]")
+		for _, v := range t {
+			n += stringSize(v) + len(",")
+		}
+		return n
+	case []interface{}:
+		n := len("[]")
+		for _, v := range t {
+			size := estimateSize(v)
+			if size < 0 {
+				return -1
+			}
+			n += size + len(",")
+		}
+		return n
+	case []map[string]interface{}:
+		n := len("[]")
+		for _, v := range t {
+			size := estimateSize(v)
+			if size < 0 {
+				return -1
+			}
+			n += size + len(",")
+		}
+		return n
+	}
+	return encodedSize(v)
+}
+
+// stringSize returns the size of s encoded as a JSON string.
+func stringSize(s string) int {
+	n := len(`""`)
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			switch {
+			case c == '"' || c == '\\' || c == '\n' || c == '\r' || c == '\t':
+				n += 2
+			case c < 0x20 || c == '<' || c == '>' || c == '&':
+				n += len(`\u0000`)
+			default:
+				n++
+			}
+			i++
+			continue
+		}
+
+		// invalid bytes are replaced with utf8.RuneError.
+		r, size := utf8.DecodeRuneInString(s[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			n += utf8.RuneLen(utf8.RuneError)
+		case r == '\u2028' || r == '\u2029':
+			n += len(`\u0000`)
+		default:
+			n += size
+		}
+		i += size
+	}
+	return n
+}
+
+// floatSize returns an upper bound of the size of f encoded as JSON, or -1
+// if it can't be encoded.
+func floatSize(f float64) int {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return -1
+	}
+	return len("-1.2345678901234567e-308")
+}
+
+// dropUnencodable removes the custom data fields that can't be encoded,
+// returning their names.
+func dropUnencodable(custom map[string]interface{}) []string {
+	var dropped []string
+	for k, v := range custom {
+		if estimateSize(v) < 0 {
+			delete(custom, k)
+			dropped = append(dropped, k)
+		}
+	}
+	sort.Strings(dropped)
+	return dropped
+}
+
+// shortenItem shortens the strings in the item's title, messages, custom
+// data and request to limit bytes, returning the number shortened.
+func shortenItem(data map[string]interface{}, limit int) int {
+	var n int
+
+	if title, ok := data["title"].(string); ok {
+		data["title"] = shortenString(title, limit, &n)
+	}
+
+	for _, section := range []string{"custom", "request"} {
+		if v, ok := data[section]; ok {
+			data[section] = shorten(v, limit, &n)
+		}
+	}
+
+	body, _ := data["body"].(map[string]interface{})
+	if message, ok := body["message"].(map[string]interface{}); ok {
+		if s, ok := message["body"].(string); ok {
+			message["body"] = shortenString(s, limit, &n)
+		}
+	}
+
+	traces, _ := body["trace_chain"].([]map[string]interface{})
+	for _, trace := range traces {
+		if exception, ok := trace["exception"].(map[string]interface{}); ok {
+			if s, ok := exception["message"].(string); ok {
+				exception["message"] = shortenString(s, limit, &n)
+			}
+		}
+	}
+
+	return n
+}
+
+// shorten returns v with its strings shortened to limit bytes, counting
+// them in n. Maps and slices are copied rather than modified.
+func shorten(v interface{}, limit int, n *int) interface{} {
+	switch t := v.(type) {
+	case string:
+		return shortenString(t, limit, n)
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[k] = shorten(v, limit, n)
+		}
+		return m
+	case map[string]string:
+		m := make(map[string]string, len(t))
+		for k, v := range t {
+			m[k] = shortenString(v, limit, n)
+		}
+		return m
+	case []interface{}:
+		l := make([]interface{}, len(t))
+		for i, v := range t {
+			l[i] = shorten(v, limit, n)
+		}
+		return l
+	case []string:
+		l := make([]string, len(t))
+		for i, v := range t {
+			l[i] = shortenString(v, limit, n)
+		}
+		return l
+	}
+	return v
+}
+
+// shortenString shortens s to limit bytes, noting how much was cut, and
+// counts it in n.
+func shortenString(s string, limit int, n *int) string {
+	if len(s) <= limit {
+		return s
+	}
+
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(s[cut]) {
+		cut--
+	}
+	*n++
+	return fmt.Sprintf("%s... (%d bytes truncated)", s[:cut], len(s)-cut)
+}
+
+// trimFrames keeps the innermost and outermost frames of every long stack
+// trace in the item, returning the number of frames removed.
+func trimFrames(data map[string]interface{}) int {
+	body, _ := data["body"].(map[string]interface{})
+	traces, _ := body["trace_chain"].([]map[string]interface{})
+
+	var n int
+	for _, trace := range traces {
+		frames, ok := trace["frames"].(rollbar.Stack)
+		if !ok || len(frames) <= 2*keepFrames {
+			continue
+		}
+
+		trimmed := make(rollbar.Stack, 0, 2*keepFrames)
+		trimmed = append(trimmed, frames[:keepFrames]...)
+		trimmed = append(trimmed, frames[len(frames)-keepFrames:]...)
+		trace["frames"] = trimmed
+		n += len(frames) - len(trimmed)
+	}
+	return n
+}
+
+// dropFields removes the largest custom data fields, keptFields last, until
+// at least excess bytes are removed, returning their names.
+func dropFields(custom map[string]interface{}, excess int) []string {
+	sizes := make(map[string]int, len(custom))
+	names := make([]string, 0, len(custom))
+	for k, v := range custom {
+		sizes[k] = len(k) + encodedSize(v)
+		names = append(names, k)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		if keptFields[names[i]] != keptFields[names[j]] {
+			return keptFields[names[j]]
+		}
+		if sizes[names[i]] != sizes[names[j]] {
+			return sizes[names[i]] > sizes[names[j]]
+		}
+		return names[i] < names[j]
+	})
+
+	var dropped []string
+	for _, k := range names {
+		if excess <= 0 {
+			break
+		}
+		delete(custom, k)
+		dropped = append(dropped, k)
+		excess -= sizes[k]
+	}
+	return dropped
+}
@@ -0,0 +1,140 @@
+package rollrus
+
+import (
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rollbar/rollbar-go"
+	"github.com/sirupsen/logrus"
+)
+
+func TestShortenString(t *testing.T) {
+	var n int
+	if s := shortenString("short", 10, &n); s != "short" || n != 0 {
+		t.Errorf("expected short strings to be kept, got %q", s)
+	}
+
+	s := shortenString("héllo wörld", 2, &n)
+	if s != "h... (12 bytes truncated)" || n != 1 {
+		t.Errorf("expected string to be cut at a rune boundary, got %q", s)
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	frames := make(rollbar.Stack, 50)
+	data := map[string]interface{}{
+		"title": strings.Repeat("a", 2000),
+		"body": map[string]interface{}{
+			"trace_chain": []map[string]interface{}{{"frames": frames}},
+		},
+		"custom": map[string]interface{}{
+			"msg":   strings.Repeat("m", 200),
+			"big":   strings.Repeat("b", 200),
+			"small": "s",
+		},
+	}
+
+	truncate(data, 1500)
+
+	if title := data["title"].(string); len(title) > 300 {
+		t.Errorf("expected title to be shortened, got %d bytes", len(title))
+	}
+	trace := firstTrace(data)
+	if n := len(trace["frames"].(rollbar.Stack)); n != 2*keepFrames {
+		t.Errorf("expected %d frames, got %d", 2*keepFrames, n)
+	}
+
+	custom := data["custom"].(map[string]interface{})
+	if _, ok := custom["big"]; ok {
+		t.Error("expected the largest field to be dropped")
+	}
+	if _, ok := custom["msg"]; !ok {
+		t.Error("expected msg to be kept")
+	}
+
+	tr := custom[truncatedField].(truncation)
+	if tr.Strings != 1 || tr.Frames != 30 || len(tr.DroppedFields) != 1 || tr.DroppedFields[0] != "big" {
+		t.Errorf("unexpected truncation %+v", tr)
+	}
+	if size := encodedSize(data); size > 1500 {
+		t.Errorf("expected item to fit in 1500 bytes, got %d", size)
+	}
+}
+
+func TestTruncateFits(t *testing.T) {
+	data := map[string]interface{}{"title": "foo", "custom": map[string]interface{}{}}
+	truncate(data, 2000)
+	if _, ok := data["custom"].(map[string]interface{})[truncatedField]; ok {
+		t.Error("expected items that fit not to be truncated")
+	}
+}
+
+func TestTruncateUnencodable(t *testing.T) {
+	data := map[string]interface{}{
+		"title": "foo",
+		"custom": map[string]interface{}{
+			"ch":  make(chan int),
+			"nan": math.NaN(),
+			"ok":  "fine",
+		},
+	}
+	truncate(data, 2000)
+
+	if size := encodedSize(data); size < 0 {
+		t.Fatal("expected the item to be encodable")
+	}
+	custom := data["custom"].(map[string]interface{})
+	if custom["ok"] != "fine" {
+		t.Errorf("expected encodable fields to be kept, got %v", custom)
+	}
+	tr := custom[truncatedField].(truncation)
+	if !reflect.DeepEqual(tr.DroppedFields, []string{"ch", "nan"}) {
+		t.Errorf("unexpected truncation %+v", tr)
+	}
+}
+
+func TestEstimateSize(t *testing.T) {
+	for _, v := range []interface{}{
+		nil,
+		true,
+		int64(-1 << 63),
+		1.5e-300,
+		`"quoted" \ <html> & tabs\t`,
+		"h\xe9llo \u2028 wörld \x01",
+		map[string]string{"a<": "b"},
+		map[string]interface{}{"a": []interface{}{"b", 1, false}, "c": []string{"d"}},
+		[]map[string]interface{}{{"frames": make(rollbar.Stack, 3)}},
+	} {
+		if estimate, size := estimateSize(v), encodedSize(v); estimate < size {
+			t.Errorf("%#v: estimated %d bytes, encoded to %d", v, estimate, size)
+		}
+	}
+
+	s := "h\xe9llo <\u2028> \"wörld\"\n"
+	if estimate, size := estimateSize(s), encodedSize(s); estimate != size {
+		t.Errorf("expected strings to be measured exactly, estimated %d bytes, encoded to %d", estimate, size)
+	}
+}
+
+func TestWithMaxPayloadSize(t *testing.T) {
+	h, transport := newTestHook(WithMaxPayloadSize(4096))
+
+	entry := logrus.NewEntry(nil).WithField("body", strings.Repeat("x", 10000))
+	entry.Message = "This is a test"
+	entry.Level = logrus.ErrorLevel
+	entry.Data["err"] = errors.New("boom")
+	if err := h.Fire(entry); err != nil {
+		t.Fatal("unexpected error ", err)
+	}
+
+	item := transport.data()[0]
+	if size := encodedSize(item); size > 4096 {
+		t.Errorf("expected item to fit in 4096 bytes, got %d", size)
+	}
+	if _, ok := item["custom"].(map[string]interface{})[truncatedField]; !ok {
+		t.Error("expected truncation to be recorded")
+	}
+}